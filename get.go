@@ -9,9 +9,7 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -27,10 +25,6 @@ import (
 	"golang.org/x/mod/module"
 )
 
-var (
-	goModVersionRegexp = regexp.MustCompile("^v[0-9]*$")
-)
-
 func parseTarget(rawTarget string) (name string, pkgPath string, versions []string, err error) {
 	if rawTarget == "" {
 		return "", "", nil, errors.New("target is empty, this should be filtered earlier")
@@ -58,15 +52,23 @@ func parseTarget(rawTarget string) (name string, pkgPath string, versions []stri
 		}
 	}
 
+	for i, v := range versions {
+		if v == "" || v == "none" {
+			continue
+		}
+		if versions[i], err = bingo.NormalizeVersion(v); err != nil {
+			return "", "", nil, err
+		}
+	}
+
 	name = nameOrPackage
 	if strings.Contains(nameOrPackage, "/") {
 		// Binary referenced by path, get default name from package path.
 		pkgPath = nameOrPackage
-		name = path.Base(pkgPath)
-		if pkgSplit := strings.Split(pkgPath, "/"); len(pkgSplit) > 3 && goModVersionRegexp.MatchString(name) {
-			// It's common pattern to name urls with versions in go modules. Exclude that.
-			name = pkgSplit[len(pkgSplit)-2]
+		if err := bingo.ValidatePackagePath(pkgPath); err != nil {
+			return "", "", nil, err
 		}
+		name = bingo.NameFromPackagePath(pkgPath)
 	}
 	return strings.ToLower(name), pkgPath, versions, nil
 }
@@ -194,7 +196,10 @@ func get(ctx context.Context, logger *log.Logger, c getConfig, rawTarget string)
 			if err != nil {
 				return errors.Wrapf(err, "found unparsable mod file %v. Uninstall it first via get %v@none or fix it manually.", e, name)
 			}
-			defer errcapture.Do(&err, mf.Close, "close")
+			// Close right away, as getPackage opens the same module file for edits.
+			if err := mf.Close(); err != nil {
+				return err
+			}
 
 			if mf.DirectPackage() == nil {
 				return errors.Wrapf(err, "failed to rename tool %v to %v name; found empty mod file %v; Use full path to install tool again", name, c.rename, e)
@@ -254,7 +259,10 @@ func get(ctx context.Context, logger *log.Logger, c getConfig, rawTarget string)
 			if err != nil {
 				return errors.Wrapf(err, "found unparsable mod file %v. Uninstall it first via get %v@none or fix it manually.", e, name)
 			}
-			defer errcapture.Do(&err, mf.Close, "close")
+			// Close right away, as getPackage opens the same module file for edits.
+			if err := mf.Close(); err != nil {
+				return err
+			}
 
 			if mf.DirectPackage() != nil {
 				if target.Path() != "" && target.Path() != mf.DirectPackage().Path() {
@@ -581,7 +589,12 @@ func getPackage(ctx context.Context, logger *log.Logger, c installPackageConfig,
 		tmpModFilePath = filepath.Join(c.modDir, fmt.Sprintf("%s.%d.tmp.mod", name, i))
 	}
 
-	outSumFile := strings.TrimSuffix(outModFile, ".mod") + ".sum"
+	outSumFile := bingo.SumFilePath(outModFile)
+	// Hand-written pin of package path can't be installed; report it, before go fails with a confusing error.
+	existing, existingErr := bingo.ModDirectPackage(outModFile)
+	if errors.Is(existingErr, bingo.ErrPackagePathRequire) {
+		return existingErr
+	}
 
 	// If we don't have all information or update is set, resolve version.
 	var fetchedDirectives nonRequireDirectives
@@ -591,11 +604,14 @@ func getPackage(ctx context.Context, logger *log.Logger, c installPackageConfig,
 		if err != nil {
 			return errors.Wrap(err, "create empty tmp mod file")
 		}
+		// Only the file is needed from now on; go get -d rewrites it, so don't keep it locked.
+		if err := tmpEmptyModFile.Close(); err != nil {
+			return errors.Wrap(err, "close empty tmp mod file")
+		}
 
-		defer errcapture.Do(&err, tmpEmptyModFile.Close, "close")
-
-		runnable := c.runner.With(ctx, tmpEmptyModFile.Filepath(), c.modDir, nil)
-		if err := resolvePackage(logger, c.verbose, tmpEmptyModFile.Filepath(), runnable, &target); err != nil {
+		// Build envs of the existing pin (e.g. GOPROXY) are used for resolving the version too.
+		runnable := c.runner.With(ctx, tmpEmptyModFilePath, c.modDir, existing.BuildEnvs)
+		if err := resolvePackage(logger, c.verbose, tmpEmptyModFilePath, runnable, &target); err != nil {
 			return err
 		}
 
@@ -617,6 +633,10 @@ func getPackage(ctx context.Context, logger *log.Logger, c installPackageConfig,
 	}
 	defer errcapture.Do(&err, tmpModFile.Close, "close")
 
+	if c.verbose {
+		tmpModFile.SetLogger(logger)
+	}
+
 	if !tmpModFile.IsDirectivesAutoFetchDisabled() && !fetchedDirectives.isEmpty() {
 		if err := tmpModFile.SetReplaceDirectives(fetchedDirectives.replace...); err != nil {
 			return err
@@ -735,13 +755,18 @@ func install(ctx context.Context, logger *log.Logger, r *runner.Runner, modDir s
 		return errors.Wrap(err, pkg.String())
 	}
 
+	if v, err := semver.NewVersion(modFile.GoVersion()); err == nil && v.GreaterThan(r.GoVersion()) {
+		logger.Printf("WARNING: %s was pinned using higher Go version (%v) than you are using (%v). Use newer Go version to install it if you encounter build errors.\n", pkg.String(), modFile.GoVersion(), r.GoVersion().String())
+	}
+
 	// Two purposes of doing list with mod=mod:
 	// * Check if path is pointing to non-buildable package.
 	// * Rebuild go.sum and go.mod (tidy) which is required to build with -mod=readonly (default) to work.
+	buildFlags := append(append([]string{}, pkg.BuildFlags...), modFile.GoFlags()...)
 	var listArgs []string
-	listArgs = append(listArgs, modFile.DirectPackage().BuildFlags...)
+	listArgs = append(listArgs, buildFlags...)
 	listArgs = append(listArgs, "-mod=mod", "-f={{.Name}}", pkg.Path())
-	if listOutput, err := r.With(ctx, modFile.Filepath(), modDir, nil).List(listArgs...); err != nil {
+	if listOutput, err := r.With(ctx, modFile.Filepath(), modDir, pkg.BuildEnvs).List(listArgs...); err != nil {
 		return errors.Wrap(err, "list")
 	} else if !strings.HasSuffix(listOutput, "main") {
 		return errors.Newf("package %s is non-main (go list output %q), nothing to get and build", pkg.Path(), listOutput)
@@ -750,10 +775,10 @@ func install(ctx context.Context, logger *log.Logger, r *runner.Runner, modDir s
 	gobin := gobin()
 
 	// go install does not define -modfile flag so we mimic go install with go build -o instead.
-	binPath := filepath.Join(gobin, fmt.Sprintf("%s-%s", name, pkg.Module.Version))
+	binPath := bingo.InstallBinaryPath(gobin, name, *pkg)
 
 	modCtx := r.With(ctx, modFile.Filepath(), modDir, pkg.BuildEnvs)
-	if err := modCtx.Build(pkg.Path(), binPath, pkg.BuildFlags...); err != nil {
+	if err := modCtx.Build(pkg.Path(), binPath, buildFlags...); err != nil {
 		if strings.Contains(err.Error(), "module declares its path as: ") &&
 			strings.Contains(err.Error(), fmt.Sprintf("but was required as: %v", modFile.DirectPackage().Path())) {
 
@@ -4,10 +4,18 @@
 package main
 
 import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/bwplotka/bingo/pkg/bingo"
+	"github.com/bwplotka/bingo/pkg/runner"
 	"github.com/efficientgo/core/errors"
 	"github.com/efficientgo/core/testutil"
+	"golang.org/x/mod/module"
 )
 
 func TestParseTarget(t *testing.T) {
@@ -48,6 +56,41 @@ func TestParseTarget(t *testing.T) {
 			expectedName: "bingo", expectedPkgPath: "github.com/bwplotka/bingo/v21314213532",
 			expectedVersions: []string{""},
 		},
+		{
+			target:       "example.com/foo/v2",
+			expectedName: "foo", expectedPkgPath: "example.com/foo/v2",
+			expectedVersions: []string{""},
+		},
+		{
+			target:       "example.com/foo/v2/cmd/bar",
+			expectedName: "bar", expectedPkgPath: "example.com/foo/v2/cmd/bar",
+			expectedVersions: []string{""},
+		},
+		{
+			target:       "github.com/foo/Bar/v10@v10.1.0",
+			expectedName: "bar", expectedPkgPath: "github.com/foo/Bar/v10",
+			expectedVersions: []string{"v10.1.0"},
+		},
+		{
+			target:       "example.com/foo/v2/cmd/v3",
+			expectedName: "cmd", expectedPkgPath: "example.com/foo/v2/cmd/v3",
+			expectedVersions: []string{""},
+		},
+		{
+			target:       "gopkg.in/yaml.v2",
+			expectedName: "yaml.v2", expectedPkgPath: "gopkg.in/yaml.v2",
+			expectedVersions: []string{""},
+		},
+		{
+			target:       "example.com/v2",
+			expectedName: "v2", expectedPkgPath: "example.com/v2",
+			expectedVersions: []string{""},
+		},
+		{
+			target:       "example.com/foo/version",
+			expectedName: "version", expectedPkgPath: "example.com/foo/version",
+			expectedVersions: []string{""},
+		},
 		{
 			target:       "tool@version1",
 			expectedName: "tool", expectedVersions: []string{"version1"},
@@ -68,6 +111,14 @@ func TestParseTarget(t *testing.T) {
 			target:      "tool@version1123,version13,none",
 			expectedErr: errors.New("none is not allowed when there are more than one specified Version, got: [version1123 version13 none]"),
 		},
+		{
+			target:      "not-a-real/path@v1.0.0",
+			expectedErr: errors.New(`"not-a-real/path" is not a valid package path: missing dot in first path element; expected path like github.com/org/repo/cmd/tool`),
+		},
+		{
+			target:      "tool@v1.0.0,v1.2.x",
+			expectedErr: errors.New("malformed version \"v1.2.x\"; not a valid semantic version"),
+		},
 		{
 			target:       "github.com/bwplotka/bingo/v2@v0.2.5-rc.1214,bb92924b84d060515f8eb35f428a8fd816c1d938,version1241",
 			expectedName: "bingo", expectedPkgPath: "github.com/bwplotka/bingo/v2", expectedVersions: []string{"v0.2.5-rc.1214", "bb92924b84d060515f8eb35f428a8fd816c1d938", "version1241"},
@@ -89,3 +140,41 @@ func TestParseTarget(t *testing.T) {
 	}
 
 }
+
+// getDRunnable mimics go get -d by adding indirect require of the given module to the module file.
+type getDRunnable struct {
+	runner.Runnable
+
+	modFile string
+	require module.Version
+}
+
+func (r getDRunnable) GetD(...string) (string, error) {
+	b, err := os.ReadFile(r.modFile)
+	if err != nil {
+		return "", err
+	}
+	return "", os.WriteFile(r.modFile, append(b, []byte("\nrequire "+r.require.Path+" "+r.require.Version+" // indirect\n")...), os.ModePerm)
+}
+
+func TestResolvePackage(t *testing.T) {
+	tmpModFile := filepath.Join(t.TempDir(), "faillint-e.tmp.mod")
+	testutil.Ok(t, os.WriteFile(tmpModFile, []byte("module _\n\ngo 1.14\n"), os.ModePerm))
+
+	// Resolution must not wait for the lock of bingo module file editor, e.g. of the one still open in getPackage.
+	mf, err := bingo.OpenModFile(tmpModFile)
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, mf.Close()) }()
+
+	target := bingo.Package{RelPath: "github.com/fatih/faillint"}
+	r := getDRunnable{modFile: tmpModFile, require: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}
+	done := make(chan error, 1)
+	go func() { done <- resolvePackage(log.New(io.Discard, "", 0), false, tmpModFile, r, &target) }()
+	select {
+	case err := <-done:
+		testutil.Ok(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("resolvePackage did not return; it waits for module file lock")
+	}
+	testutil.Equals(t, bingo.Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, target)
+}
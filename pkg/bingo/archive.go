@@ -0,0 +1,110 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/bwplotka/bingo/pkg/mod"
+	"github.com/efficientgo/core/errcapture"
+	"github.com/efficientgo/core/errors"
+)
+
+// InspectArchive returns direct packages pinned in bingo enhanced module files from the given zip, tar or gzipped tar
+// stream of the bingo module directory, in the archive order. Entries other than module files, the fake root go.mod
+// and module files without bingo meta comment are skipped.
+func InspectArchive(r io.Reader) (_ []Package, err error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "read")
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		// Zip central directory is at the end, so it can't be read as a stream.
+		b, err := io.ReadAll(br)
+		if err != nil {
+			return nil, errors.Wrap(err, "read")
+		}
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, errors.Wrap(err, "zip")
+		}
+		return inspectZip(zr)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.Wrap(err, "gzip")
+		}
+		defer errcapture.Do(&err, gr.Close, "close")
+		return inspectTar(tar.NewReader(gr))
+	default:
+		return inspectTar(tar.NewReader(br))
+	}
+}
+
+func inspectZip(zr *zip.Reader) (pkgs []Package, _ error) {
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !isArchivedModFile(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrap(err, f.Name)
+		}
+		p, err := archivedDirectPackages(f.Name, rc)
+		if cerr := rc.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, p...)
+	}
+	return pkgs, nil
+}
+
+func inspectTar(tr *tar.Reader) (pkgs []Package, _ error) {
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return pkgs, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "tar")
+		}
+		if h.Typeflag != tar.TypeReg || !isArchivedModFile(h.Name) {
+			continue
+		}
+		p, err := archivedDirectPackages(h.Name, tr)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, p...)
+	}
+}
+
+func isArchivedModFile(name string) bool {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	return strings.HasSuffix(base, ".mod") && base != FakeRootModFileName
+}
+
+func archivedDirectPackages(name string, r io.Reader) ([]Package, error) {
+	mf, err := mod.Parse(name, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, comment := mf.Module(); !hasMetaComment(comment) {
+		return nil, nil
+	}
+	return directPackages(mf, ParseDirectConfig{})
+}
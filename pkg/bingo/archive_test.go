@@ -0,0 +1,77 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/efficientgo/core/testutil"
+	"golang.org/x/mod/module"
+)
+
+var archivedFiles = []struct {
+	name    string
+	content string
+}{
+	{name: ".bingo/", content: ""},
+	{name: ".bingo/go.mod", content: "module _ // Fake go.mod auto-created by 'bingo' for go -moddir compatibility with non-Go projects. Commit this file, together with other .mod files.\n"},
+	{name: ".bingo/Variables.mk", content: "GO ?= $(shell which go)\n"},
+	{name: ".bingo/faillint.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"},
+	{name: ".bingo/other.mod", content: "module github.com/yolo/other\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"},
+	{name: ".bingo/prometheus.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1\n"},
+}
+
+func TestInspectArchive(t *testing.T) {
+	expected := []Package{
+		{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}},
+		{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus", BuildEnvs: []string{"CGO_ENABLED=1"}},
+	}
+
+	zipped := &bytes.Buffer{}
+	zw := zip.NewWriter(zipped)
+	for _, f := range archivedFiles {
+		w, err := zw.Create(f.name)
+		testutil.Ok(t, err)
+		_, err = io.WriteString(w, f.content)
+		testutil.Ok(t, err)
+	}
+	testutil.Ok(t, zw.Close())
+
+	tarred := &bytes.Buffer{}
+	tw := tar.NewWriter(tarred)
+	for _, f := range archivedFiles {
+		h := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if f.content == "" {
+			h.Typeflag = tar.TypeDir
+		}
+		testutil.Ok(t, tw.WriteHeader(h))
+		_, err := io.WriteString(tw, f.content)
+		testutil.Ok(t, err)
+	}
+	testutil.Ok(t, tw.Close())
+
+	gzipped := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzipped)
+	_, err := gw.Write(tarred.Bytes())
+	testutil.Ok(t, err)
+	testutil.Ok(t, gw.Close())
+
+	for name, b := range map[string][]byte{"zip": zipped.Bytes(), "tar": tarred.Bytes(), "tar.gz": gzipped.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			pkgs, err := InspectArchive(bytes.NewReader(b))
+			testutil.Ok(t, err)
+			testutil.Equals(t, expected, pkgs)
+		})
+	}
+	t.Run("empty", func(t *testing.T) {
+		pkgs, err := InspectArchive(bytes.NewReader(nil))
+		testutil.Ok(t, err)
+		testutil.Equals(t, 0, len(pkgs))
+	})
+}
@@ -0,0 +1,114 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/bwplotka/bingo/pkg/mod"
+	"github.com/efficientgo/core/errcapture"
+	"github.com/efficientgo/core/errors"
+)
+
+// ModEditor batches edits of the direct package of bingo enhanced module file in memory and writes them all at once
+// on Flush. Like OpenModFile, it adds meta if missing. The module file on disk is not touched until Flush. The module
+// file is locked like for mod.OpenFile until Close, so concurrent edits are not lost.
+type ModEditor struct {
+	f  *mod.File
+	mf *ModFile
+}
+
+// NewModEditor opens and parses given bingo enhanced module file for batched edits.
+// It's a caller responsibility to Close the editor when not using anymore.
+func NewModEditor(modFile string) (_ *ModEditor, err error) {
+	f, err := mod.OpenFile(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			errcapture.Do(&err, f.Close, "close")
+		}
+	}()
+
+	b := &bytes.Buffer{}
+	if _, err := f.WriteTo(b); err != nil {
+		return nil, err
+	}
+	mf, err := ParseModFile(modFile, b)
+	if err != nil {
+		return nil, err
+	}
+	if mf.DirectPackage() == nil {
+		return nil, errors.Wrap(ErrNoDirectPackage, modFile)
+	}
+	return &ModEditor{f: f, mf: mf}, nil
+}
+
+// DirectPackage returns the direct package with all edits made so far.
+func (e *ModEditor) DirectPackage() Package {
+	return *e.mf.DirectPackage()
+}
+
+func (e *ModEditor) edit(f func(p *Package)) error {
+	p := e.DirectPackage()
+	f(&p)
+	return e.mf.SetDirectRequire(p)
+}
+
+// SetVersion sets version of the direct package.
+func (e *ModEditor) SetVersion(version string) error {
+	return e.edit(func(p *Package) { p.Module.Version = version })
+}
+
+// SetModulePath changes module path of the direct package, preserving version and build meta. If the current package
+// path is within the new module, package suffix is recomputed relative to it, so e.g. moving a command to its own
+// module keeps the package path. Otherwise, package suffix is kept as is, like for a module moved to a new repository.
+func (e *ModEditor) SetModulePath(modulePath string) error {
+	return e.edit(func(p *Package) { setModulePath(p, modulePath) })
+}
+
+func setModulePath(p *Package, modulePath string) {
+	if pkgPath := p.Path(); pkgPath == modulePath || strings.HasPrefix(pkgPath, modulePath+"/") {
+		p.RelPath = strings.TrimPrefix(strings.TrimPrefix(pkgPath, modulePath), "/")
+	}
+	p.Module.Path = modulePath
+}
+
+// SetRelPath sets package suffix of the direct package. Empty relPath means module path is the package path.
+func (e *ModEditor) SetRelPath(relPath string) error {
+	return e.edit(func(p *Package) { p.RelPath = relPath })
+}
+
+// SetBuildEnvs sets build environment variables of the direct package, e.g. "CGO_ENABLED=1".
+func (e *ModEditor) SetBuildEnvs(envs ...string) error {
+	return e.edit(func(p *Package) { p.BuildEnvs = envs })
+}
+
+// SetBuildFlags sets build flags of the direct package, e.g. "-tags=netgo".
+func (e *ModEditor) SetBuildFlags(flags ...string) error {
+	return e.edit(func(p *Package) { p.BuildFlags = flags })
+}
+
+// SetToolchain sets toolchain directive (Go 1.21+) recording the Go toolchain the tool requires, e.g. "go1.21.3".
+// Empty toolchain removes the directive.
+func (e *ModEditor) SetToolchain(toolchain string) error {
+	return e.mf.SetToolchain(toolchain)
+}
+
+// Flush writes all edits to the module file at once. The file is replaced atomically, so readers see either the old
+// or the new content.
+func (e *ModEditor) Flush() error {
+	b := &bytes.Buffer{}
+	if _, err := e.mf.WriteTo(b); err != nil {
+		return err
+	}
+	return e.f.SetContent(b.Bytes())
+}
+
+// Close releases the module file lock. Edits not flushed are discarded.
+func (e *ModEditor) Close() error {
+	return e.f.Close()
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bwplotka/bingo/pkg/mod"
+	"github.com/efficientgo/core/errors"
+	"github.com/efficientgo/core/testutil"
+	"golang.org/x/mod/module"
+)
+
+func TestModEditor(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	original := `module _
+
+go 1.14
+
+replace github.com/miekg/dns => github.com/miekg/dns v1.0.4
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`
+	testFile := filepath.Join(tmpDir, "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(original), 0600))
+
+	e, err := NewModEditor(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, e.SetVersion("v2.5.0+incompatible"))
+	testutil.Ok(t, e.SetRelPath("cmd/promtool"))
+	testutil.Ok(t, e.SetBuildEnvs("CGO_ENABLED=1"))
+	testutil.Ok(t, e.SetBuildFlags("-tags=netgo,osusergo"))
+	testutil.Equals(t, "github.com/prometheus/prometheus/cmd/promtool@v2.5.0+incompatible", e.DirectPackage().String())
+
+	// Nothing is written before flush.
+	expectContent(t, original, testFile)
+
+	testutil.Ok(t, e.Flush())
+	testutil.Ok(t, e.Close())
+	expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+replace github.com/miekg/dns => github.com/miekg/dns v1.0.4
+
+require github.com/prometheus/prometheus v2.5.0+incompatible // cmd/promtool CGO_ENABLED=1 -tags=netgo,osusergo
+`, testFile)
+
+	fi, err := os.Stat(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, os.FileMode(0600), fi.Mode())
+
+	// No leftovers, except the lock file.
+	files, err := filepath.Glob(filepath.Join(tmpDir, "*"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{testFile, mod.LockFilePath(testFile)}, files)
+
+	testFile = filepath.Join(tmpDir, "empty.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n"), os.ModePerm))
+	_, err = NewModEditor(testFile)
+	testutil.NotOk(t, err)
+	testutil.Assert(t, errors.Is(err, ErrNoDirectPackage), "expected ErrNoDirectPackage, got %v", err)
+}
+
+func TestModEditor_Locked(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"), os.ModePerm))
+
+	e, err := NewModEditor(testFile)
+	testutil.Ok(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		// Blocks until the editor is closed, then edits its result.
+		done <- SetModTag(testFile, "v1.6.0")
+	}()
+
+	testutil.Ok(t, e.SetVersion("v1.6.0"))
+	testutil.Ok(t, e.Flush())
+	testutil.Ok(t, e.Close())
+	testutil.Ok(t, <-done)
+
+	expectContent(t, "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT // tag: v1.6.0\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.6.0\n", testFile)
+}
+
+func TestModEditor_SetToolchain(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.21\n\nrequire github.com/fatih/faillint v1.5.0\n"), os.ModePerm))
+
+	_, ok, err := ModToolchain(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok)
+
+	e, err := NewModEditor(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, e.SetToolchain("go1.21.3"))
+	testutil.Ok(t, e.SetVersion("v1.6.0"))
+	testutil.Ok(t, e.Flush())
+	testutil.Ok(t, e.Close())
+	expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.21
+
+toolchain go1.21.3
+
+require github.com/fatih/faillint v1.6.0
+`, testFile)
+
+	toolchain, ok, err := ModToolchain(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, ok)
+	testutil.Equals(t, "go1.21.3", toolchain)
+
+	// Survives bingo rewrites.
+	mf, err := OpenModFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}))
+	testutil.Ok(t, mf.Close())
+	toolchain, _, err = ModToolchain(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "go1.21.3", toolchain)
+}
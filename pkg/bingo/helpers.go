@@ -4,8 +4,12 @@
 package bingo
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/efficientgo/core/errors"
@@ -50,8 +54,15 @@ type templateData struct {
 	RelModDir    string
 }
 
-func genHelper(f, tmpl, relModDir, version string, pkgs []PackageRenderable) error {
-	t, err := template.New(f).Parse(tmpl)
+// RenderMakefile writes Makefile helper (as in generated Variables.mk) for the given tools to w. For each tool it
+// defines a variable with binary path and a target that (re)installs the binary, if missing or older than the tool
+// module file. Tools are rendered in the given order, see SortRenderables.
+func RenderMakefile(pkgs []PackageRenderable, version string, w io.Writer) error {
+	return renderHelper("Variables.mk", templatesByFileExt["mk"], version, pkgs, w)
+}
+
+func renderHelper(name, tmpl, version string, pkgs []PackageRenderable, w io.Writer) error {
+	t, err := template.New(name).Parse(tmpl)
 	if err != nil {
 		return errors.Wrap(err, "parse template")
 	}
@@ -60,7 +71,10 @@ func genHelper(f, tmpl, relModDir, version string, pkgs []PackageRenderable) err
 		Version:      version,
 		MainPackages: pkgs,
 	}
+	return t.Execute(w, data)
+}
 
+func genHelper(f, tmpl, relModDir, version string, pkgs []PackageRenderable) (err error) {
 	fb, err := os.Create(filepath.Join(relModDir, f))
 	if err != nil {
 		return errors.Wrap(err, "create")
@@ -74,5 +88,36 @@ func genHelper(f, tmpl, relModDir, version string, pkgs []PackageRenderable) err
 			err = cerr
 		}
 	}()
-	return t.Execute(fb, data)
+	return renderHelper(f, tmpl, version, pkgs, fb)
+}
+
+// RenderEnvFile writes env file with one "<variable name>='<package>@<version>'" line per given tool, e.g. for
+// "go install" in shell scripts. Array tools get all versions, space separated. Lines are sorted by variable name
+// and values are quoted for sh, so the output is deterministic and safe to source.
+func RenderEnvFile(pkgs []PackageRenderable, w io.Writer) error {
+	lines := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		targets := make([]string, 0, len(p.Versions))
+		for _, v := range p.Versions {
+			targets = append(targets, p.PackagePath+"@"+v.Version)
+		}
+		name := p.EnvVarName
+		if name == "" {
+			name = VariableName(p.Name)
+		}
+		lines = append(lines, name+"="+shellQuote(strings.Join(targets, " ")))
+	}
+	sort.Strings(lines)
+
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s in single quotes, so sh does not expand anything in it.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
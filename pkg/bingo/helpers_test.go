@@ -0,0 +1,59 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/efficientgo/core/testutil"
+)
+
+func TestRenderEnvFile(t *testing.T) {
+	pkgs := []PackageRenderable{
+		{
+			Name: "promtool", EnvVarName: "PROMTOOL", PackagePath: "github.com/prometheus/prometheus/cmd/promtool",
+			Versions: []PackageVersionRenderable{{Version: "v2.4.3+incompatible", ModFile: "promtool.mod"}},
+		},
+		{
+			Name: "faillint", EnvVarName: "FAILLINT_ARRAY", PackagePath: "github.com/fatih/faillint",
+			Versions: []PackageVersionRenderable{{Version: "v1.5.0", ModFile: "faillint.mod"}, {Version: "v1.4.0", ModFile: "faillint.1.mod"}},
+		},
+		{
+			Name: "golangci-lint", PackagePath: "github.com/golangci/golangci-lint/cmd/golangci-lint",
+			Versions: []PackageVersionRenderable{{Version: "it's", ModFile: "golangci-lint.mod"}},
+		},
+	}
+
+	b := &bytes.Buffer{}
+	testutil.Ok(t, RenderEnvFile(pkgs, b))
+	testutil.Equals(t, `FAILLINT_ARRAY='github.com/fatih/faillint@v1.5.0 github.com/fatih/faillint@v1.4.0'
+GOLANGCI_LINT='github.com/golangci/golangci-lint/cmd/golangci-lint@it'\''s'
+PROMTOOL='github.com/prometheus/prometheus/cmd/promtool@v2.4.3+incompatible'
+`, b.String())
+}
+
+func TestRenderMakefile(t *testing.T) {
+	pkgs := PackageRenderables{
+		{
+			Name: "promtool", EnvVarName: "PROMTOOL", PackagePath: "github.com/prometheus/prometheus/cmd/promtool",
+			Versions:     []PackageVersionRenderable{{Version: "v2.4.3+incompatible", ModFile: "promtool.mod"}},
+			BuildEnvVars: []string{"CGO_ENABLED=1"}, BuildFlags: []string{"-tags=netgo"},
+		},
+		{
+			Name: "faillint", EnvVarName: "FAILLINT_ARRAY", PackagePath: "github.com/fatih/faillint",
+			Versions: []PackageVersionRenderable{{Version: "v1.5.0", ModFile: "faillint.mod"}, {Version: "v1.4.0", ModFile: "faillint.1.mod"}},
+		},
+	}
+	SortRenderables(pkgs)
+
+	b := &bytes.Buffer{}
+	testutil.Ok(t, RenderMakefile(pkgs, "v0.6", b))
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "Variables.mk.golden"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, string(golden), b.String())
+}
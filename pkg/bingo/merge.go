@@ -0,0 +1,123 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Conflict represents tool pinned differently in two merged directories.
+type Conflict struct {
+	// Name is the tool name in the first directory.
+	Name string
+	// A and B are the tool pins from the first and the second directory. Pins conflict if they have the same name,
+	// but different package path, or the same package path, but different names or versions.
+	A, B PackageRenderable
+}
+
+func (c Conflict) String() string {
+	if c.A.Name != c.B.Name {
+		return c.A.Name + ": " + conflictPinString(c.A) + " vs " + c.B.Name + ": " + conflictPinString(c.B)
+	}
+	return c.Name + ": " + conflictPinString(c.A) + " vs " + conflictPinString(c.B)
+}
+
+func conflictPinString(p PackageRenderable) string {
+	var versions []string
+	for _, v := range p.Versions {
+		versions = append(versions, v.Version)
+	}
+	return p.PackagePath + "@" + strings.Join(versions, ",")
+}
+
+// MergePins copies tool pins (module and sum files) from both given bingo module directories to the out directory,
+// e.g. when combining two projects. Tools are matched by name or package path. Tools pinned the same way (same name,
+// package path and versions) in both directories are copied once, conflicting ones are returned and not copied at
+// all, so they can be resolved manually. Variables files are not copied; run bingo get in the out directory to
+// regenerate them.
+func MergePins(dirA, dirB, out string) ([]Conflict, error) {
+	discard := log.New(io.Discard, "", 0)
+	pinsA, err := ListPinnedMainPackages(discard, dirA, false)
+	if err != nil {
+		return nil, err
+	}
+	pinsB, err := ListPinnedMainPackages(discard, dirB, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(out, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	var conflicts []Conflict
+	toCopy := map[string]PackageRenderable{}
+	from := map[string]string{}
+	for _, a := range pinsA {
+		toCopy[a.Name], from[a.Name] = a, dirA
+	}
+	for _, b := range pinsB {
+		a, ok := toCopy[b.Name]
+		if !ok {
+			for _, other := range pinsA {
+				if other.PackagePath == b.PackagePath {
+					a, ok = other, true
+					break
+				}
+			}
+		}
+		switch {
+		case !ok:
+			toCopy[b.Name], from[b.Name] = b, dirB
+		case a.Name != b.Name || a.PackagePath != b.PackagePath || !sameVersions(a, b):
+			conflicts = append(conflicts, Conflict{Name: a.Name, A: a, B: b})
+			delete(toCopy, a.Name)
+		}
+	}
+
+	for name, p := range toCopy {
+		for _, v := range p.Versions {
+			if err := copyPinFiles(filepath.Join(from[name], v.ModFile), filepath.Join(out, v.ModFile)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, dir := range []string{dirA, dirB} {
+		if _, err := os.Stat(filepath.Join(out, FakeRootModFileName)); err == nil {
+			break
+		}
+		if err := copyFile(filepath.Join(dir, FakeRootModFileName), filepath.Join(out, FakeRootModFileName)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Name < conflicts[j].Name })
+	return conflicts, nil
+}
+
+func sameVersions(a, b PackageRenderable) bool {
+	if len(a.Versions) != len(b.Versions) {
+		return false
+	}
+	for i := range a.Versions {
+		if a.Versions[i].Version != b.Versions[i].Version {
+			return false
+		}
+	}
+	return true
+}
+
+func copyPinFiles(modFile, outModFile string) error {
+	if err := copyFile(modFile, outModFile); err != nil {
+		return err
+	}
+	if err := copyFile(SumFilePath(modFile), SumFilePath(outModFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
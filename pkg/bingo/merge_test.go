@@ -0,0 +1,100 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/efficientgo/core/testutil"
+)
+
+func writePin(t *testing.T, dir, file, require string) {
+	t.Helper()
+
+	testutil.Ok(t, os.MkdirAll(dir, os.ModePerm))
+	testutil.Ok(t, os.WriteFile(filepath.Join(dir, file), []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire "+require+"\n"), os.ModePerm))
+}
+
+func TestMergePins(t *testing.T) {
+	for _, tcase := range []struct {
+		name              string
+		pinsB             map[string]string
+		expectedConflicts []string
+		expectedFiles     []string
+	}{
+		{
+			name: "same pins",
+			pinsB: map[string]string{
+				"faillint.mod": "github.com/fatih/faillint v1.5.0",
+				"promtool.mod": "github.com/prometheus/prometheus v2.4.3+incompatible // cmd/promtool",
+			},
+			expectedFiles: []string{"faillint.mod", "faillint.sum", "go.mod", "goimports.mod", "promtool.mod"},
+		},
+		{
+			name: "new pin",
+			pinsB: map[string]string{
+				"faillint.mod":  "github.com/fatih/faillint v1.5.0",
+				"copyright.mod": "github.com/efficientgo/tools/copyright v0.0.0-20210201224146-3d78f4d30648",
+			},
+			expectedFiles: []string{"copyright.mod", "faillint.mod", "faillint.sum", "go.mod", "goimports.mod", "promtool.mod"},
+		},
+		{
+			name:              "different version",
+			pinsB:             map[string]string{"goimports.mod": "golang.org/x/tools v0.1.6 // cmd/goimports"},
+			expectedConflicts: []string{"goimports: golang.org/x/tools/cmd/goimports@v0.1.5 vs golang.org/x/tools/cmd/goimports@v0.1.6"},
+			expectedFiles:     []string{"faillint.mod", "faillint.sum", "go.mod", "promtool.mod"},
+		},
+		{
+			name:              "different package path",
+			pinsB:             map[string]string{"faillint.mod": "github.com/bwplotka/faillint v1.5.0"},
+			expectedConflicts: []string{"faillint: github.com/fatih/faillint@v1.5.0 vs github.com/bwplotka/faillint@v1.5.0"},
+			expectedFiles:     []string{"go.mod", "goimports.mod", "promtool.mod"},
+		},
+		{
+			name:              "different name",
+			pinsB:             map[string]string{"prom-tool.mod": "github.com/prometheus/prometheus v2.4.3+incompatible // cmd/promtool"},
+			expectedConflicts: []string{"promtool: github.com/prometheus/prometheus/cmd/promtool@v2.4.3+incompatible vs prom-tool: github.com/prometheus/prometheus/cmd/promtool@v2.4.3+incompatible"},
+			expectedFiles:     []string{"faillint.mod", "faillint.sum", "go.mod", "goimports.mod"},
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			dirA, dirB, out := filepath.Join(tmpDir, "a"), filepath.Join(tmpDir, "b"), filepath.Join(tmpDir, "out")
+
+			writePin(t, dirA, "faillint.mod", "github.com/fatih/faillint v1.5.0")
+			testutil.Ok(t, os.WriteFile(filepath.Join(dirA, "faillint.sum"), []byte("github.com/fatih/faillint v1.5.0 h1:yolo=\n"), os.ModePerm))
+			writePin(t, dirA, "goimports.mod", "golang.org/x/tools v0.1.5 // cmd/goimports")
+			writePin(t, dirA, "promtool.mod", "github.com/prometheus/prometheus v2.4.3+incompatible // cmd/promtool")
+			testutil.Ok(t, os.WriteFile(filepath.Join(dirA, FakeRootModFileName), []byte("module _\n"), os.ModePerm))
+			for file, require := range tcase.pinsB {
+				writePin(t, dirB, file, require)
+			}
+
+			conflicts, err := MergePins(dirA, dirB, out)
+			testutil.Ok(t, err)
+			var got []string
+			for _, c := range conflicts {
+				got = append(got, c.String())
+			}
+			testutil.Equals(t, tcase.expectedConflicts, got)
+
+			entries, err := os.ReadDir(out)
+			testutil.Ok(t, err)
+			var files []string
+			for _, e := range entries {
+				files = append(files, e.Name())
+			}
+			testutil.Equals(t, tcase.expectedFiles, files)
+
+			if require, ok := tcase.pinsB["copyright.mod"]; ok {
+				pkg, err := ModDirectPackage(filepath.Join(out, "copyright.mod"))
+				testutil.Ok(t, err)
+				testutil.Equals(t, strings.Replace(require, " ", "@", 1), pkg.String())
+			}
+		})
+	}
+}
@@ -4,22 +4,34 @@
 package bingo
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/bwplotka/bingo/pkg/envars"
 	"github.com/bwplotka/bingo/pkg/mod"
 	"github.com/bwplotka/bingo/pkg/runner"
 	"github.com/efficientgo/core/errcapture"
 	"github.com/efficientgo/core/errors"
+	"github.com/efficientgo/core/merrors"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 )
 
 const (
@@ -31,9 +43,50 @@ const (
 	PackageRenderablesPrintHeader = "Name\tBinary Name\tPackage @ Version\tBuild EnvVars\tBuild Flags\n" +
 		"----\t-----------\t-----------------\t-------------\t-----------\n"
 
-	metaComment = "Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT"
+	// LegacyMetaComment is the meta comment used by upstream bingo. Module files with it are always recognized as
+	// bingo enhanced, no matter what MetaComment is set to.
+	LegacyMetaComment = "Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT"
 )
 
+// MetaComment is the comment put on the module line of each bingo enhanced module file. It can be changed (e.g. by
+// forks or for renamed binaries) before any module file is opened.
+var MetaComment = LegacyMetaComment
+
+// MetaCommentFirst controls where meta comment is put if the module line already has other comment, e.g.
+// "module _ // <meta> // <other>" if true or "module _ // <other> // <meta>" if false (default). Other comments are
+// always kept.
+var MetaCommentFirst = false
+
+// WritePinnedAt controls if AddMetaToModFor puts "pinned <RFC3339 time>" comment on the module line, recording when
+// the pin was last changed for audit purposes. It's informational only and does not affect builds. Disabled by default,
+// so regenerated module files are deterministic, e.g. in CI.
+var WritePinnedAt = false
+
+// now is used for pinned-at comments, so it can be changed in tests.
+var now = time.Now
+
+const (
+	pinnedAtPrefix = "pinned "
+	tagPrefix      = "tag: "
+	groupPrefix    = "group: "
+)
+
+// moduleCommentSep separates comments on the module line. Meta comment itself contains "//" in the URL, so spaces
+// around are required.
+const moduleCommentSep = " // "
+
+var goModVersionRegexp = regexp.MustCompile("^v[0-9]+$")
+
+var groupRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ErrNoDirectPackage is returned when bingo module file has no direct require, e.g. because module file is empty or
+// all requires are marked as indirect.
+var ErrNoDirectPackage = errors.New("no direct package found; empty module?")
+
+// ErrPackagePathRequire is returned when direct require of bingo module file has package path instead of module path,
+// e.g. "require github.com/fatih/faillint/cmd/faillint v1.5.0" written by hand. Such pins can't be installed.
+var ErrPackagePathRequire = errors.New("this pinning scheme is not supported")
+
 // NameFromModFile returns binary name from module file path.
 func NameFromModFile(modFile string) (name string, oneOfMany bool) {
 	n := strings.Split(strings.TrimSuffix(filepath.Base(modFile), ".mod"), ".")
@@ -43,6 +96,45 @@ func NameFromModFile(modFile string) (name string, oneOfMany bool) {
 	return n[0], oneOfMany
 }
 
+// NameFromPackagePath returns default tool name for the given package path, which is the last path element, unless
+// it's a major version suffix (e.g. /v2) of the module path. The element before it is used then.
+func NameFromPackagePath(pkgPath string) string {
+	pkgSplit := strings.Split(strings.TrimSuffix(pkgPath, "/"), "/")
+	name := pkgSplit[len(pkgSplit)-1]
+	if len(pkgSplit) > 2 && goModVersionRegexp.MatchString(name) {
+		// It's common pattern to name urls with versions in go modules. Exclude that.
+		name = pkgSplit[len(pkgSplit)-2]
+	}
+	return name
+}
+
+// DetectNameCollisions returns groups of different packages that get the same default tool name (see
+// NameFromPackagePath) and would install binaries with the same name, by that name, e.g. "server" for both
+// github.com/a/server and github.com/b/cmd/server. Versions are ignored, as many versions of the same package are
+// pinned as an array. Such tools need custom names, e.g. using bingo get -n. Only colliding names are returned.
+func DetectNameCollisions(pkgs []Package) map[string][]Package {
+	byName := map[string][]Package{}
+	for _, p := range pkgs {
+		name := strings.ToLower(NameFromPackagePath(p.Path()))
+		dup := false
+		for _, other := range byName[name] {
+			if other.Path() == p.Path() {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			byName[name] = append(byName[name], p)
+		}
+	}
+	for name, group := range byName {
+		if len(group) < 2 {
+			delete(byName, name)
+		}
+	}
+	return byName
+}
+
 // A Package (for clients, a bingo.Package) is defined by a module path, package relative path and version pair.
 // These are stored in their plain (unescaped) form.
 type Package struct {
@@ -70,7 +162,19 @@ func (m Package) String() string {
 
 // Path returns a full package path.
 func (m Package) Path() string {
-	return filepath.Join(m.Module.Path, m.RelPath)
+	return path.Join(m.Module.Path, m.RelPath)
+}
+
+// Equal returns true if both packages refer to the same package path at the same version, no matter how package
+// path is split into module path and package suffix. Build envs and flags are not compared.
+func (m Package) Equal(other Package) bool {
+	return m.Path() == other.Path() && m.Module.Version == other.Module.Version
+}
+
+// IsUntagged returns true if package is pinned to a pseudo-version, which means the pin is a commit not tied to any
+// tag, e.g. resolved from a branch name.
+func (m Package) IsUntagged() bool {
+	return IsPseudoVersion(m.Module.Version)
 }
 
 // ModFile is a wrapper over module file with bingo specific data.
@@ -79,6 +183,10 @@ type ModFile struct {
 
 	directPackage               *Package
 	directivesAutoFetchDisabled bool
+	goFlags                     []string
+
+	// logger is used to log changes made to the file, if set.
+	logger *log.Logger
 }
 
 // OpenModFile opens bingo mod file.
@@ -94,13 +202,118 @@ func OpenModFile(modFile string) (_ *ModFile, err error) {
 			errcapture.Do(&err, f.Close, "close")
 		}
 	}()
+	return newModFile(f)
+}
+
+// ParseModFile parses bingo module file content from the given reader. Like OpenModFile, it adds meta if missing and
+// trims all require direct module imports except first, but all changes are kept in memory. Use WriteTo to get
+// the resulting content e.g. for dry runs. The name is used only for diagnostics.
+func ParseModFile(name string, r io.Reader) (*ModFile, error) {
+	f, err := mod.Parse(name, r)
+	if err != nil {
+		return nil, err
+	}
+	return newModFile(f)
+}
+
+// MigrateLegacy returns content of the given legacy bingo pin migrated to the current format, without touching any file.
+// The legacy pin has the shape of bingo pin (module line "_" or none and exactly one direct require, with package meta
+// comment, if any), but no bingo meta comment in the module line, like pins written by hand or by old bingo versions.
+// Migrated content has meta comment added and requires in the form bingo writes them; see ParseModFile. Content of
+// the pin already in the current format is returned unchanged. Error is returned for any other module file, e.g. with
+// module path or more direct requires. The name is used only for diagnostics.
+func MigrateLegacy(name string, r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read")
+	}
+	f, err := mod.Parse(name, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	p, comment := f.Module()
+	if hasMetaComment(comment) {
+		return b, nil
+	}
+	if p != "" && p != "_" {
+		return nil, errors.Newf("%s: unrecognized pin format; module path %q found, expected \"_\" or none", name, p)
+	}
+	direct := 0
+	for _, r := range f.RequireDirectives() {
+		if !r.Indirect {
+			direct++
+		}
+	}
+	if direct != 1 {
+		return nil, errors.Newf("%s: unrecognized pin format; expected exactly one direct require, found %d", name, direct)
+	}
+
+	mf, err := ParseModFile(name, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	if _, err := mf.WriteTo(out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// CheckRoundTrip checks if module file content from the given reader is kept intact when bingo adds its meta to it.
+// It parses given content, adds meta in memory and parses the result again, returning an error describing the drift
+// of the direct package (including version and build meta), if any. It also checks that adding meta again does not
+// change content anymore. The name is used only for diagnostics.
+func CheckRoundTrip(name string, r io.Reader) error {
+	orig, err := mod.Parse(name, r)
+	if err != nil {
+		return err
+	}
+	expected, err := directPackages(orig, ParseDirectConfig{})
+	if err != nil {
+		return err
+	}
+
+	b := &bytes.Buffer{}
+	if _, err := orig.WriteTo(b); err != nil {
+		return err
+	}
+	mf, err := ParseModFile(name, b)
+	if err != nil {
+		return errors.Wrap(err, "add meta")
+	}
+	withMeta := &bytes.Buffer{}
+	if _, err := mf.WriteTo(withMeta); err != nil {
+		return err
+	}
+	if got := mf.DirectPackage(); got == nil || !reflect.DeepEqual(expected[0], *got) {
+		return errors.Newf("%s: direct package drifted after adding meta; expected %+v, got %+v", name, expected[0], got)
+	}
+
+	again, err := ParseModFile(name, bytes.NewReader(withMeta.Bytes()))
+	if err != nil {
+		return errors.Wrap(err, "parse with meta")
+	}
+	if got := again.DirectPackage(); got == nil || !reflect.DeepEqual(expected[0], *got) {
+		return errors.Newf("%s: direct package drifted after parsing module file with meta; expected %+v, got %+v", name, expected[0], got)
+	}
+	b.Reset()
+	if _, err := again.WriteTo(b); err != nil {
+		return err
+	}
+	if b.String() != withMeta.String() {
+		return errors.Newf("%s: adding meta is not idempotent; got %q after first and %q after second time", name, withMeta.String(), b.String())
+	}
+	return nil
+}
 
+func newModFile(f *mod.File) (*ModFile, error) {
 	m, comment := f.Module()
 	if m == "" {
 		m = "_"
 	}
-	if comment != metaComment {
-		if err := f.SetModule(m, metaComment); err != nil {
+	if !hasMetaComment(comment) {
+		if err := f.SetModule(m, withMetaComment(comment)); err != nil {
 			return nil, err
 		}
 	}
@@ -109,10 +322,27 @@ func OpenModFile(modFile string) (_ *ModFile, err error) {
 	return mf, mf.Reload()
 }
 
+// SetLogger sets logger used to log what changes are made to the module file and why. Nil disables logging.
+func (mf *ModFile) SetLogger(logger *log.Logger) {
+	mf.logger = logger
+}
+
+func (mf *ModFile) logf(format string, args ...interface{}) {
+	if mf.logger == nil {
+		return
+	}
+	mf.logger.Printf("%s: "+format+"\n", append([]interface{}{mf.Filepath()}, args...)...)
+}
+
 func (mf *ModFile) IsDirectivesAutoFetchDisabled() bool {
 	return mf.directivesAutoFetchDisabled
 }
 
+// GoFlags returns extra go flags from the goflags comment of the module file, if any; see ModGoFlags.
+func (mf *ModFile) GoFlags() []string {
+	return mf.goFlags
+}
+
 func (mf *ModFile) Reload() error {
 	if err := mf.File.Reload(); err != nil {
 		return err
@@ -124,31 +354,55 @@ func (mf *ModFile) Reload() error {
 			break
 		}
 	}
+	goFlags, err := parseGoFlags(mf.Filepath(), mf.Comments())
+	if err != nil {
+		return err
+	}
+	mf.goFlags = goFlags
 
 	// We expect just one direct import if any.
-	var directPackage *Package
-	for _, r := range mf.RequireDirectives() {
+	reqs := mf.RequireDirectives()
+	for _, r := range reqs {
 		if r.Indirect {
 			continue
 		}
 
-		directPackage = &Package{Module: r.Module}
-		if len(r.ExtraSuffixComment) > 0 {
-			directPackage.RelPath, directPackage.BuildEnvs, directPackage.BuildFlags = parseDirectPackageMeta(strings.Trim(r.ExtraSuffixComment, "\n"))
+		pkg := directPackageFromRequire(r)
+		if len(reqs) == 1 && reqs[0] == directRequire(pkg) {
+			// Already in expected form, don't rewrite the file.
+			mf.directPackage = &pkg
+			return nil
 		}
-		break
-	}
-
-	if directPackage != nil {
-		return mf.SetDirectRequire(*directPackage)
+		mf.logf("require directives are not in expected form, rewriting")
+		return mf.SetDirectRequire(pkg)
 	}
 	return nil
 }
 
+// directPackageFromRequire decodes bingo package meta (if any) from the direct require directive.
+func directPackageFromRequire(r mod.RequireDirective) Package {
+	pkg := Package{Module: r.Module}
+	pkg.RelPath, pkg.BuildEnvs, pkg.BuildFlags = parseDirectPackageMeta(requireComments(r))
+	pkg.RelPath = toSlash(pkg.RelPath)
+	return pkg
+}
+
+// requireComments returns space separated elements of the require directive suffix comment.
+func requireComments(r mod.RequireDirective) []string {
+	return strings.Fields(r.ExtraSuffixComment)
+}
+
+// SumFilePath returns path of the sum file kept next to the given module file, e.g. ".bingo/foo.sum" for
+// ".bingo/foo.mod" and ".bingo/go.sum" for the shared ".bingo/go.mod".
 func SumFilePath(modFilePath string) string {
 	return strings.TrimSuffix(modFilePath, ".mod") + ".sum"
 }
 
+// ModSumEntries returns sum entries of the sum file kept next to the given module file, see mod.SumEntries.
+func ModSumEntries(modFile string) (map[string][]string, error) {
+	return mod.SumEntries(SumFilePath(modFile))
+}
+
 // CreateFromExistingOrNew creates and opens new bingo enhanced module file.
 // If existing file exists and is not malformed it copies this as the source, otherwise completely new is created.
 // It's a caller responsibility to Close the file when not using anymore.
@@ -190,6 +444,36 @@ func CreateFromExistingOrNew(ctx context.Context, r *runner.Runner, logger *log.
 	return OpenModFile(modFile)
 }
 
+// RenderMinimalMod returns content of the new bingo enhanced module file pinning the given package: module line with
+// meta comment, go directive with the given Go version and the direct require with package meta comment, as written
+// by bingo get. It allows creating the pin from scratch without go mod init and editing it afterwards.
+func RenderMinimalMod(p Package, goVersion string) ([]byte, error) {
+	if p.Module.Version == "" {
+		return nil, errors.Newf("%s: no version to pin", p.Path())
+	}
+	mf, err := ParseModFile("minimal.mod", strings.NewReader("module _\n"))
+	if err != nil {
+		return nil, err
+	}
+	if WritePinnedAt {
+		if err := mf.SetModule("_", withPinnedAt(withMetaComment(""), now())); err != nil {
+			return nil, err
+		}
+	}
+	if err := mf.SetGoVersion(goVersion); err != nil {
+		return nil, err
+	}
+	if err := mf.SetDirectRequire(p); err != nil {
+		return nil, err
+	}
+
+	b := &bytes.Buffer{}
+	if _, err := mf.WriteTo(b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
 func copyFile(src, dst string) (err error) {
 	source, err := os.Open(src)
 	if err != nil {
@@ -220,24 +504,29 @@ func copyFile(src, dst string) (err error) {
 	return nil
 }
 
-func parseDirectPackageMeta(line string) (relPath string, buildEnv []string, buildFlags []string) {
-	elem := strings.Split(line, " ")
+// parseDirectPackageMeta parses elements of the direct require comment. Elements with "=" are build envs and all
+// elements starting from the first one with "-" prefix are build flags. For backward compatibility the first other
+// element is the package suffix. Further ones are ignored, so tooling can encode its own hints there.
+func parseDirectPackageMeta(elem []string) (relPath string, buildEnv []string, buildFlags []string) {
+	relPathSet := false
 	for i, l := range elem {
-		if l == "" {
-			continue
-		}
-
 		if l[0] == '-' {
 			buildFlags = elem[i:]
 			break
 		}
 
 		if !strings.Contains(l, "=") {
-			relPath = l
+			if !relPathSet {
+				relPath, relPathSet = l, true
+			}
 			continue
 		}
 		buildEnv = append(buildEnv, l)
 	}
+	if relPath == "." {
+		// Same as no package suffix, which is how it's written back.
+		relPath = ""
+	}
 	return relPath, buildEnv, buildFlags
 }
 
@@ -247,13 +536,76 @@ func (mf *ModFile) DirectPackage() *Package {
 
 // SetDirectRequire removes all require statements and set to the given one. It supports package level versioning.
 func (mf *ModFile) SetDirectRequire(target Package) (err error) {
+	if target.Module.Path != "" {
+		if err := ValidateModulePath(target.Module.Path); err != nil {
+			return err
+		}
+	}
+	if err := validateRelPath(target); err != nil {
+		return err
+	}
+	if mf.directPackage != nil {
+		mf.logf("setting direct require to %v (previously %v)", target.String(), mf.directPackage.String())
+	} else {
+		mf.logf("setting direct require to %v", target.String())
+	}
+	mf.directPackage = &target
+	return mf.SetRequireDirectives(directRequire(target))
+}
+
+// toSlash returns package relative path with forward slashes, also for paths written on Windows.
+func toSlash(relPath string) string {
+	return strings.ReplaceAll(relPath, "\\", "/")
+}
+
+// ValidateModulePath returns error if given path is not a valid module path, e.g. "not-a-real/path" that has no dot
+// in the first path element, so typos are caught before go is run.
+func ValidateModulePath(p string) error {
+	if err := module.CheckPath(p); err != nil {
+		return errors.Newf("%q is not a valid module path: %v; expected path like github.com/org/repo", p, unwrapInvalidPath(err))
+	}
+	return nil
+}
+
+// ValidatePackagePath is like ValidateModulePath, but for package paths, which can contain elements (e.g. "/v1" or
+// sub-packages of gopkg.in modules) not allowed in module paths.
+func ValidatePackagePath(p string) error {
+	err := module.CheckImportPath(p)
+	if err == nil {
+		err = module.CheckPath(strings.SplitN(p, "/", 2)[0])
+	}
+	if err != nil {
+		return errors.Newf("%q is not a valid package path: %v; expected path like github.com/org/repo/cmd/tool", p, unwrapInvalidPath(err))
+	}
+	return nil
+}
+
+func unwrapInvalidPath(err error) error {
+	var perr *module.InvalidPathError
+	if errors.As(err, &perr) {
+		return perr.Err
+	}
+	return err
+}
+
+// validateRelPath returns error if package suffix of the given package points outside its module, e.g. "../other".
+func validateRelPath(target Package) error {
+	rel := path.Clean(toSlash(target.RelPath))
+	if rel == ".." || strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+		return errors.Newf("package suffix %q points outside of %s module (package path %s)", target.RelPath, target.Module.Path, path.Join(target.Module.Path, target.RelPath))
+	}
+	return nil
+}
+
+// directRequire encodes bingo package meta (if any) into the direct require directive.
+func directRequire(target Package) mod.RequireDirective {
 	r := mod.RequireDirective{Module: target.Module}
 
 	var meta []string
 
 	// Add sub package info if needed.
 	if target.RelPath != "" && target.RelPath != "." {
-		meta = append(meta, target.RelPath)
+		meta = append(meta, toSlash(target.RelPath))
 	}
 	meta = append(meta, target.BuildEnvs...)
 	meta = append(meta, target.BuildFlags...)
@@ -261,123 +613,1440 @@ func (mf *ModFile) SetDirectRequire(target Package) (err error) {
 	if len(meta) > 0 {
 		r.ExtraSuffixComment = strings.Join(meta, " ")
 	}
-	mf.directPackage = &target
-	return mf.SetRequireDirectives(r)
+	return r
 }
 
-// ModDirectPackage return the first direct package from bingo enhanced module file. The package suffix (if any) is
-// encoded in the line comment, in the same line as module and version.
-func ModDirectPackage(modFile string) (pkg Package, err error) {
-	mf, err := OpenModFile(modFile)
-	if err != nil {
-		return Package{}, err
+// SetVersion sets version of the direct require of the given module in bingo enhanced module file.
+// Package meta encoded in the require comment is preserved.
+func SetVersion(modFile, modulePath, version string) (err error) {
+	if err := ValidateModulePath(modulePath); err != nil {
+		return err
 	}
-	defer errcapture.Do(&err, mf.Close, "close")
-
-	if mf.directPackage == nil {
-		return Package{}, errors.Newf("no direct package found in %s; empty module?", mf.Filepath())
+	e, err := NewModEditor(modFile)
+	if err != nil && !errors.Is(err, ErrNoDirectPackage) {
+		return err
 	}
-	return *mf.directPackage, nil
-}
-
-// ModIndirectModules return the all indirect mod from any module file.
-func ModIndirectModules(modFile string) (mods []module.Version, err error) {
-	m, err := mod.OpenFile(modFile)
-	if err != nil {
-		return nil, err
+	if e != nil {
+		defer errcapture.Do(&err, e.Close, "close")
 	}
-
-	for _, r := range m.RequireDirectives() {
-		if !r.Indirect {
-			continue
-		}
-
-		mods = append(mods, r.Module)
+	if e == nil || e.DirectPackage().Module.Path != modulePath {
+		return errors.Newf("no direct require of %s module found in %s", modulePath, modFile)
 	}
-	return mods, nil
+	if err := e.SetVersion(version); err != nil {
+		return err
+	}
+	return e.Flush()
 }
 
-// PackageVersionRenderable is used in variables.go. Modify with care.
-type PackageVersionRenderable struct {
-	Version string
-	ModFile string
+// DiffVersion returns the current version of the direct package from bingo enhanced module file and whether setting
+// it to newVersion would change it, without modifying the module file, e.g. for printing "old -> new" before upgrade.
+func DiffVersion(modFile, newVersion string) (old string, changed bool, err error) {
+	pkg, err := ModDirectPackage(modFile)
+	if err != nil {
+		return "", false, err
+	}
+	return pkg.Module.Version, pkg.Module.Version != newVersion, nil
 }
 
-// PackageRenderable is used in variables.go. Modify with care.
-type PackageRenderable struct {
-	Name        string
-	ModPath     string
-	PackagePath string
-	EnvVarName  string
-	Versions    []PackageVersionRenderable
-
-	BuildFlags   []string
-	BuildEnvVars []string
+// RenameModulePath changes module path of the direct require of the given module in bingo enhanced module file, e.g.
+// after the tool migrated to a new import path. Version and build meta are preserved; package suffix is recomputed
+// relative to the new module path, see ModEditor.SetModulePath.
+func RenameModulePath(modFile, oldPath, newPath string) (err error) {
+	if err := ValidateModulePath(newPath); err != nil {
+		return err
+	}
+	e, err := NewModEditor(modFile)
+	if err != nil && !errors.Is(err, ErrNoDirectPackage) {
+		return err
+	}
+	if e != nil {
+		defer errcapture.Do(&err, e.Close, "close")
+	}
+	if e == nil || e.DirectPackage().Module.Path != oldPath {
+		return errors.Newf("no direct require of %s module found in %s", oldPath, modFile)
+	}
+	if err := e.SetModulePath(newPath); err != nil {
+		return err
+	}
+	return e.Flush()
 }
 
-func (p PackageRenderable) ToPackages() []Package {
-	ret := make([]Package, 0, len(p.Versions))
-	for _, v := range p.Versions {
-		relPath, _ := filepath.Rel(p.ModPath, p.PackagePath)
+// UpgradePreservingSubPackage sets module path and version of the direct package in bingo enhanced module file, e.g.
+// when upgrade crosses major version and module path gets "/v2" suffix, so that the same package (command) is
+// targeted. Package suffix is kept if only the major version suffix of the module path changes; otherwise it's
+// recomputed like in RenameModulePath. Error is returned if the version is not valid for the new module path, or if
+// the package cannot be within the new module, e.g. new module is a nested module of the old one, not containing
+// the package. Build meta is preserved.
+func UpgradePreservingSubPackage(modFile, newModulePath, newVersion string) (err error) {
+	if err := module.Check(newModulePath, newVersion); err != nil {
+		return errors.Wrap(err, modFile)
+	}
+	e, err := NewModEditor(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, e.Close, "close")
 
-		ret = append(ret, Package{
-			Module: module.Version{
-				Version: v.Version,
-				Path:    p.ModPath,
-			},
-			RelPath: relPath,
-		})
+	pkg := e.DirectPackage()
+	oldPrefix, _, _ := module.SplitPathVersion(pkg.Module.Path)
+	newPrefix, _, _ := module.SplitPathVersion(newModulePath)
+	switch pkgPath := pkg.Path(); {
+	case oldPrefix == newPrefix:
+		pkg.Module.Path = newModulePath
+	case strings.HasPrefix(newModulePath, pkg.Module.Path+"/") && pkgPath != newModulePath && !strings.HasPrefix(pkgPath, newModulePath+"/"):
+		return errors.Newf("%s: package %s does not resolve under the new module path %s", modFile, pkgPath, newModulePath)
+	default:
+		setModulePath(&pkg, newModulePath)
 	}
-	return ret
+	// Module path and version are set at once, as module path with major version suffix requires matching version.
+	pkg.Module.Version = newVersion
+	if err := e.mf.SetDirectRequire(pkg); err != nil {
+		return err
+	}
+	return e.Flush()
 }
 
-type PackageRenderables []PackageRenderable
+// AddMetaToModFor adds bingo meta to the module file, setting package suffix only for the direct require of the given
+// module. Other require directives and build meta of the given one are not touched, so it's safe to use for module
+// files with many direct requires. Empty relPath means the module path is the package path.
+func AddMetaToModFor(modFile, modulePath, relPath string) (err error) {
+	if err := ValidateModulePath(modulePath); err != nil {
+		return err
+	}
+	mf, err := mod.OpenFile(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
 
-func (pkgs PackageRenderables) PrintTab(target string, w io.Writer) error {
-	tw := new(tabwriter.Writer)
-	tw.Init(w, 1, 8, 1, '\t', tabwriter.AlignRight)
-	defer func() { _ = tw.Flush() }()
+	var target *mod.RequireDirective
+	for _, r := range mf.RequireDirectives() {
+		if !r.Indirect && r.Module.Path == modulePath {
+			target = &r
+			break
+		}
+	}
+	if target == nil {
+		return errors.Newf("no direct require of %s module found in %s", modulePath, modFile)
+	}
 
-	_, _ = fmt.Fprint(tw, PackageRenderablesPrintHeader)
-	for _, p := range pkgs {
-		if target != "" && p.Name != target {
-			continue
+	if p, comment := mf.Module(); !hasMetaComment(comment) || WritePinnedAt {
+		if p == "" {
+			p = "_"
 		}
-		for _, v := range p.Versions {
-			fields := []string{
-				p.Name,
-				p.Name + "-" + v.Version,
-				p.PackagePath + "@" + v.Version,
-				strings.Join(p.BuildEnvVars, " "),
-				strings.Join(p.BuildFlags, " "),
-			}
-			_, _ = fmt.Fprintln(tw, strings.Join(fields, "\t"))
+		if !hasMetaComment(comment) {
+			comment = withMetaComment(comment)
 		}
-		if target != "" {
-			return nil
+		if WritePinnedAt {
+			comment = withPinnedAt(comment, now())
+		}
+		if err := mf.SetModule(p, comment); err != nil {
+			return err
 		}
 	}
 
-	if target != "" {
-		return errors.Newf("Pinned tool %s not found", target)
+	pkg := directPackageFromRequire(*target)
+	pkg.RelPath = relPath
+	if err := validateRelPath(pkg); err != nil {
+		return err
+	}
+	if d := directRequire(pkg); d.ExtraSuffixComment != target.ExtraSuffixComment {
+		return mf.SetRequireComment(modulePath, d.ExtraSuffixComment)
 	}
 	return nil
 }
 
-// ListPinnedMainPackages lists all bingo pinned binaries (Go main packages) in the same order as seen in the filesystem.
-func ListPinnedMainPackages(logger *log.Logger, modDir string, remMalformed bool) (pkgs PackageRenderables, _ error) {
-	modFiles, err := filepath.Glob(filepath.Join(modDir, "*.mod"))
+// ModDirectPackage return the first direct package from bingo enhanced module file. The package suffix (if any) is
+// encoded in the line comment, in the same line as module and version. ErrNoDirectPackage is returned if there is none.
+func ModDirectPackage(modFile string) (pkg Package, err error) {
+	pkgs, err := ModDirectPackages(modFile)
+	if err != nil {
+		return Package{}, err
+	}
+	return pkgs[0], nil
+}
+
+// Reproduce returns the package pinned in the given bingo enhanced module file, with exact version and build meta
+// needed to get the same pin again from scratch, e.g. "bingo get <pkg.String()>", to verify the version still resolves
+// the same way. Error is returned if module file is not valid (see ValidateModFile) or the pin has no version, as such
+// pin cannot be reproduced. Module file is not modified.
+func Reproduce(modFile string) (Package, error) {
+	if err := ValidateModFile(modFile); err != nil {
+		return Package{}, err
+	}
+	pkg, err := ModDirectPackage(modFile)
+	if err != nil {
+		return Package{}, err
+	}
+	if pkg.Module.Version == "" {
+		return Package{}, errors.Newf("%s: pin of %s has no version to reproduce", modFile, pkg.Path())
+	}
+	return pkg, nil
+}
+
+// ModDirectPackages return all direct packages from bingo enhanced module file in the order of require directives
+// in the file. The package suffix (if any) is encoded in the line comment, in the same line as module and version.
+// ErrNoDirectPackage is returned if there is none. Module file is not modified. ErrPackagePathRequire is returned for
+// requires of full package path instead of module path, written by hand, if module root can be found in the sum file.
+func ModDirectPackages(modFile string) ([]Package, error) {
+	return ParseDirect(modFile, ParseDirectConfig{})
+}
+
+// ParseDirectConfig configures how direct packages are parsed from bingo enhanced module file.
+type ParseDirectConfig struct {
+	// AllowIndirectFallback promotes the only require directive to the direct one if it is marked as indirect (e.g. by
+	// accident). By default, such module file has no direct package and ErrNoDirectPackage is returned.
+	AllowIndirectFallback bool
+	// Logger is used to warn about promotion, if not nil.
+	Logger *log.Logger
+}
+
+// ParseDirect is like ModDirectPackages, but allows configuring parsing with ParseDirectConfig.
+func ParseDirect(modFile string, cfg ParseDirectConfig) (pkgs []Package, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	pkgs, err = directPackages(mf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Problems with the sum file itself are reported by ValidateModFile; they must not make the pin unreadable.
+	if sums, err := ModSumEntries(modFile); err == nil {
+		for _, p := range pkgs {
+			if err := packagePathRequireErr(modFile, p, sums); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// packagePathRequireErr returns ErrPackagePathRequire if the given direct package is pinned with full package path in
+// the require, detected using the sum file entries.
+func packagePathRequireErr(modFile string, pkg Package, sums map[string][]string) error {
+	if splitFullImportPath(pkg, sums).Module.Path == pkg.Module.Path {
+		return nil
+	}
+	return errors.Wrapf(ErrPackagePathRequire, "%s: require path %s is a package path, not a module path; remove the module file and run bingo get %s@%s again to fix the pin", modFile, pkg.Module.Path, pkg.Path(), pkg.Module.Version)
+}
+
+// splitFullImportPath returns package with module path and package suffix split, if the given package was pinned by
+// hand with full package path in the require (e.g. "require github.com/fatih/faillint/cmd/faillint v1.5.0"), which
+// is not a valid module root. It's only used to detect such pins; parsing never splits, so pin reads the same from
+// any source. Module root is the longest path prefix that has hashes for the same version in the sum file. Package is
+// returned unchanged if require path itself or none of its prefixes has such hashes.
+func splitFullImportPath(pkg Package, sums map[string][]string) Package {
+	if hasSumEntry(sums, pkg.Module) {
+		return pkg
+	}
+	for p := path.Dir(pkg.Module.Path); p != "." && p != "/"; p = path.Dir(p) {
+		if !hasSumEntry(sums, module.Version{Path: p, Version: pkg.Module.Version}) {
+			continue
+		}
+		split := pkg
+		split.Module.Path = p
+		split.RelPath = path.Join(strings.TrimPrefix(pkg.Module.Path, p+"/"), toSlash(pkg.RelPath))
+		return split
+	}
+	return pkg
+}
+
+func hasSumEntry(sums map[string][]string, m module.Version) bool {
+	_, ok := sums[m.String()]
+	if !ok {
+		_, ok = sums[m.String()+"/go.mod"]
+	}
+	return ok
+}
+
+// VerifySumCoverage returns error if the sum file kept next to the given module file (see SumFilePath) has no hash of
+// the direct require module content, which is needed to build the package without fetching it, e.g. offline. The
+// go.mod file hash alone is not enough. ErrNoDirectPackage is returned if there is no direct package.
+func VerifySumCoverage(modFile string) error {
+	pkgs, err := ParseDirect(modFile, ParseDirectConfig{})
+	if err != nil {
+		return err
+	}
+	sums, err := ModSumEntries(modFile)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if _, ok := sums[pkg.Module.String()]; !ok {
+			return errors.Newf("%s: missing checksum for %s in %s; run bingo get %s again to fix the pin", modFile, pkg.String(), SumFilePath(modFile), pkg.String())
+		}
+	}
+	return nil
+}
+
+// ModDirectPackageFS is like ModDirectPackage, but reads module file with the given name from the given filesystem
+// e.g. embed.FS with default tool pins.
+func ModDirectPackageFS(fsys fs.FS, name string) (_ Package, err error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return Package{}, err
+	}
+	defer errcapture.Do(&err, f.Close, "close")
+
+	mf, err := mod.Parse(name, f)
+	if err != nil {
+		return Package{}, err
+	}
+	pkgs, err := directPackages(mf, ParseDirectConfig{})
+	if err != nil {
+		return Package{}, err
+	}
+	return pkgs[0], nil
+}
+
+// ModDirectPackageLenient is like ModDirectPackage, but it does not stop on the first broken line of the module file.
+// It returns errors for all broken lines together with the direct package recovered from the rest of the file, if any.
+func ModDirectPackageLenient(modFile string) (_ Package, errs []error) {
+	f, err := os.Open(modFile)
+	if err != nil {
+		return Package{}, []error{err}
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			errs = append(errs, errors.Wrap(err, "close"))
+		}
+	}()
+
+	mf, errs := mod.ParseLenient(modFile, f)
+	if mf == nil {
+		return Package{}, errs
+	}
+	pkgs, err := directPackages(mf, ParseDirectConfig{})
+	if err != nil {
+		return Package{}, append(errs, err)
+	}
+	return pkgs[0], errs
+}
+
+func directPackages(mf mod.FileForRead, cfg ParseDirectConfig) (pkgs []Package, _ error) {
+	modFile := mf.Filepath()
+	reqs := mf.RequireDirectives()
+	for _, r := range reqs {
+		if r.Indirect {
+			continue
+		}
+		pkgs = append(pkgs, directPackageFromRequire(r))
+	}
+	if len(pkgs) == 0 && cfg.AllowIndirectFallback && len(reqs) == 1 {
+		if cfg.Logger != nil {
+			cfg.Logger.Printf("warning: %s: the only require of %s module is marked as indirect; treating it as direct\n", modFile, reqs[0].Module.Path)
+		}
+		r := reqs[0]
+		r.Indirect = false
+		pkgs = append(pkgs, directPackageFromRequire(r))
+	}
+	if len(pkgs) == 0 {
+		return nil, errors.Wrap(ErrNoDirectPackage, modFile)
+	}
+	// Reject pins that can't be written back, so reads and writes of module files agree.
+	for _, p := range pkgs {
+		if err := validateRelPath(p); err != nil {
+			return nil, errors.Wrap(err, modFile)
+		}
+	}
+	return pkgs, nil
+}
+
+// ModProxy returns GOPROXY build env of the first direct package from bingo enhanced module file, so a tool can be
+// fetched from a different proxy than the rest. Empty string is returned if not set, meaning ambient GOPROXY is used.
+func ModProxy(modFile string) (string, error) {
+	pkg, err := ModDirectPackage(modFile)
+	if err != nil {
+		return "", err
+	}
+	proxy, _ := pkg.BuildEnvs.Lookup("GOPROXY")
+	return proxy, nil
+}
+
+// Inspect returns the first direct package and true if module file has bingo meta comment, from a single parse of the
+// module file. ErrNoDirectPackage is returned if there is no direct package. Module file is not modified.
+func Inspect(modFile string) (_ Package, hasMeta bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return Package{}, false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	return inspect(mf)
+}
+
+// InspectReader is like Inspect, but parses module file content from the given reader, e.g. os.Stdin. Name is used
+// only in errors, e.g. "-" or "<stdin>".
+func InspectReader(name string, r io.Reader) (_ Package, hasMeta bool, err error) {
+	mf, err := mod.Parse(name, r)
+	if err != nil {
+		return Package{}, false, err
+	}
+	return inspect(mf)
+}
+
+func inspect(mf mod.FileForRead) (Package, bool, error) {
+	_, comment := mf.Module()
+	pkgs, err := directPackages(mf, ParseDirectConfig{})
+	if err != nil {
+		return Package{}, hasMetaComment(comment), err
+	}
+	return pkgs[0], hasMetaComment(comment), nil
+}
+
+// ModDirectComments returns all elements of the comment on the first direct require from bingo enhanced module file
+// e.g. ["cmd/prometheus", "CGO_ENABLED=1", "-tags=yolo"]. The first element that is neither build env (contains "=")
+// nor build flag (has "-" prefix) is treated as the package suffix for backward compatibility; see ModDirectPackage.
+// ErrNoDirectPackage is returned if there is no direct require. Module file is not modified.
+func ModDirectComments(modFile string) (_ []string, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	for _, r := range mf.RequireDirectives() {
+		if r.Indirect {
+			continue
+		}
+		return requireComments(r), nil
+	}
+	return nil, errors.Wrap(ErrNoDirectPackage, modFile)
+}
+
+// RemoveMetaFromMod removes bingo meta from the module file, so it can be used as a plain module file. This means
+// bingo meta comment in the module line and package meta comment (package suffix, build envs and flags) in the direct
+// requires. All other comments and directives are preserved. It's a noop if module file has no bingo meta comment.
+func RemoveMetaFromMod(modFile string) (err error) {
+	mf, err := mod.OpenFile(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	p, comment := mf.Module()
+	if !hasMetaComment(comment) {
+		return nil
+	}
+	if err := mf.SetModule(p, withoutMetaComment(comment)); err != nil {
+		return err
+	}
+	for _, r := range mf.RequireDirectives() {
+		if r.Indirect || r.ExtraSuffixComment == "" {
+			continue
+		}
+		if err := mf.SetRequireComment(r.Module.Path, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isMetaComment(c string) bool {
+	return c == MetaComment || c == LegacyMetaComment
+}
+
+func hasMetaComment(comment string) bool {
+	for _, c := range strings.Split(comment, moduleCommentSep) {
+		if isMetaComment(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// withMetaComment returns module line comment with meta comment placed according to MetaCommentFirst.
+func withMetaComment(comment string) string {
+	switch {
+	case comment == "":
+		return MetaComment
+	case MetaCommentFirst:
+		return MetaComment + moduleCommentSep + comment
+	default:
+		return comment + moduleCommentSep + MetaComment
+	}
+}
+
+func withoutMetaComment(comment string) string {
+	var other []string
+	for _, c := range strings.Split(comment, moduleCommentSep) {
+		if !isMetaComment(c) && !strings.HasPrefix(c, pinnedAtPrefix) && !strings.HasPrefix(c, tagPrefix) && !strings.HasPrefix(c, groupPrefix) {
+			other = append(other, c)
+		}
+	}
+	return strings.Join(other, moduleCommentSep)
+}
+
+// withModuleComment returns module line comment with the element with the given prefix set to prefix+value, replacing
+// the previous one. Empty value removes the element.
+func withModuleComment(comment, prefix, value string) string {
+	other := []string{}
+	for _, c := range strings.Split(comment, moduleCommentSep) {
+		if c != "" && !strings.HasPrefix(c, prefix) {
+			other = append(other, c)
+		}
+	}
+	if value != "" {
+		other = append(other, prefix+value)
+	}
+	return strings.Join(other, moduleCommentSep)
+}
+
+// moduleCommentValue returns value of the module line comment element with the given prefix, if any.
+func moduleCommentValue(comment, prefix string) (string, bool) {
+	for _, c := range strings.Split(comment, moduleCommentSep) {
+		if strings.HasPrefix(c, prefix) {
+			return strings.TrimPrefix(c, prefix), true
+		}
+	}
+	return "", false
+}
+
+// withPinnedAt returns module line comment with pinned-at comment set to the given time, replacing the previous one.
+func withPinnedAt(comment string, t time.Time) string {
+	return withModuleComment(comment, pinnedAtPrefix, t.UTC().Format(time.RFC3339))
+}
+
+// ModPinnedAt returns time from the "pinned <RFC3339 time>" comment in the module line and true, or false if there is
+// no such comment; see WritePinnedAt. Module file is not modified.
+func ModPinnedAt(modFile string) (_ time.Time, ok bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	_, comment := mf.Module()
+	v, ok := moduleCommentValue(comment, pinnedAtPrefix)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "%s: parse pinned-at comment %q", modFile, pinnedAtPrefix+v)
+	}
+	return t, true, nil
+}
+
+// SetModTag puts "tag: <tag>" comment on the module line of bingo enhanced module file, recording the human-friendly
+// tag (e.g. "v1.2.3") that resolved to the pinned version, useful when the pin is a pseudo-version. It's informational
+// only and does not affect builds. Empty tag removes the comment.
+func SetModTag(modFile, tag string) (err error) {
+	if tag != "" && !semver.IsValid(tag) {
+		return errors.Newf("tag %q is not a valid semantic version, expected e.g. v1.2.3", tag)
+	}
+	mf, err := mod.OpenFile(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	p, comment := mf.Module()
+	if !hasMetaComment(comment) {
+		return errors.Newf("%s: not a bingo enhanced module file, no bingo meta comment in the module line", modFile)
+	}
+	if newComment := withModuleComment(comment, tagPrefix, tag); newComment != comment {
+		return mf.SetModule(p, newComment)
+	}
+	return nil
+}
+
+// ModTag returns tag from the "tag: <tag>" comment in the module line and true, or false if there is no such comment;
+// see SetModTag. Module file is not modified.
+func ModTag(modFile string) (_ string, ok bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return "", false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	_, comment := mf.Module()
+	tag, ok := moduleCommentValue(comment, tagPrefix)
+	return tag, ok, nil
+}
+
+// SetModGroup puts "group: <group>" comment on the module line of bingo enhanced module file, so pins can be grouped
+// e.g. into "linters", "codegen" or "release" tools. It's informational only, does not affect builds and is kept
+// when module file is changed by bingo. Empty group removes the comment.
+func SetModGroup(modFile, group string) (err error) {
+	if group != "" && !groupRegexp.MatchString(group) {
+		return errors.Newf("group %q is malformed, expected letters, digits, '.', '_' or '-', e.g. linters", group)
+	}
+	mf, err := mod.OpenFile(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	p, comment := mf.Module()
+	if !hasMetaComment(comment) {
+		return errors.Newf("%s: not a bingo enhanced module file, no bingo meta comment in the module line", modFile)
+	}
+	if newComment := withModuleComment(comment, groupPrefix, group); newComment != comment {
+		return mf.SetModule(p, newComment)
+	}
+	return nil
+}
+
+// ModGroup returns group from the "group: <group>" comment in the module line and true, or empty group and false if
+// there is no such comment; see SetModGroup. Module file is not modified.
+func ModGroup(modFile string) (_ string, ok bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return "", false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	_, comment := mf.Module()
+	group, ok := moduleCommentValue(comment, groupPrefix)
+	return group, ok, nil
+}
+
+const goFlagsCommentPrefix = "goflags:"
+
+// safeGoFlags are flags allowed in goflags comment with their allowed values, by flag name. Nil means any value.
+// Flags that can run arbitrary commands (e.g. -toolexec or -ldflags with -extld) or change where files are read from
+// or written to (e.g. -modfile, -o) are not allowed. -mod is not allowed either, as bingo always builds with -mod=mod.
+var safeGoFlags = map[string][]string{
+	"-tags":     nil,
+	"-trimpath": {"", "true", "false"},
+	"-buildvcs": {"true", "false", "auto"},
+}
+
+// ModGoFlags returns extra go flags from the "goflags: <flags>" comment of bingo enhanced module file, e.g.
+// "// goflags: -trimpath -tags=netgo" in its own line before the go directive. Flags are used together with package
+// build flags when the tool is installed. Only known-safe flags are allowed, error is returned for others. Nil is
+// returned if there is no such comment. Module file is not modified.
+func ModGoFlags(modFile string) (_ []string, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	return parseGoFlags(modFile, mf.Comments())
+}
+
+func parseGoFlags(modFile string, comments []string) (flags []string, _ error) {
+	for _, c := range comments {
+		if !strings.HasPrefix(c, goFlagsCommentPrefix) {
+			continue
+		}
+		for _, f := range strings.Fields(strings.TrimPrefix(c, goFlagsCommentPrefix)) {
+			name, value := f, ""
+			if i := strings.Index(f, "="); i >= 0 {
+				name, value = f[:i], f[i+1:]
+			}
+			allowed, ok := safeGoFlags[name]
+			if !ok {
+				return nil, errors.Newf("%s: flag %q in goflags comment is not allowed; allowed flags: -buildvcs, -tags, -trimpath", modFile, f)
+			}
+			if allowed != nil && !contains(allowed, value) {
+				return nil, errors.Newf("%s: flag %q in goflags comment has not allowed value; allowed values: %s", modFile, f, strings.Join(allowed, ", "))
+			}
+			flags = append(flags, f)
+		}
+	}
+	return flags, nil
+}
+
+func contains(elems []string, e string) bool {
+	for _, el := range elems {
+		if el == e {
+			return true
+		}
+	}
+	return false
+}
+
+// ModHasMeta returns true if module file has bingo meta comment (current MetaComment or LegacyMetaComment) in the module line.
+func ModHasMeta(modFile string) (_ bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	_, comment := mf.Module()
+	return hasMetaComment(comment), nil
+}
+
+// ModToolchain returns toolchain from the toolchain directive (Go 1.21+) of the given module file, e.g. "go1.21.3", and
+// true, or false if there is no such directive. Module file is not modified.
+func ModToolchain(modFile string) (_ string, ok bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return "", false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	toolchain := mf.Toolchain()
+	return toolchain, toolchain != "", nil
+}
+
+// MetaComplete returns true if bingo enhanced module file has both bingo meta comment in the module line and package
+// meta consistent with the tool name. It detects half-annotated files, e.g. "goimports.mod" with meta comment, but
+// with "// cmd/goimports" package suffix stripped from "require golang.org/x/tools v0.1.5", which would install the
+// wrong package. Since the package suffix can't be recovered, heuristic is used: file without package suffix is
+// reported if its tool name (see NameFromModFile) differs from the default name of the module (see
+// NameFromPackagePath), so a tool with custom name (bingo get -n) and no suffix is reported too. Use AddMetaToModFor
+// to repair the package suffix. False is returned if there is no meta comment. Module file is not modified.
+func MetaComplete(modFile string) (_ bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	if _, comment := mf.Module(); !hasMetaComment(comment) {
+		return false, nil
+	}
+	pkgs, err := directPackages(mf, ParseDirectConfig{})
+	if err != nil {
+		return false, err
+	}
+	if pkgs[0].RelPath != "" {
+		return true, nil
+	}
+	name, _ := NameFromModFile(modFile)
+	return strings.EqualFold(name, NameFromPackagePath(pkgs[0].Module.Path)), nil
+}
+
+// ModName returns path from the module line of the given module file, e.g. "_" for bingo enhanced module files.
+// Empty string is returned if module file has no module line.
+func ModName(modFile string) (_ string, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return "", err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	p, _ := mf.Module()
+	return p, nil
+}
+
+// VerifyMeta checks if bingo enhanced module file has bingo meta comment in the module line and if package suffix
+// (if any) of each direct require resolves to the package within the required module.
+func VerifyMeta(modFile string) (err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	if p, comment := mf.Module(); !hasMetaComment(comment) {
+		line := "module " + p
+		if comment != "" {
+			line += " // " + comment
+		}
+		return errors.Newf("%s: module line %q does not have bingo meta comment %q", modFile, line, MetaComment)
+	}
+
+	for _, r := range mf.RequireDirectives() {
+		if r.Indirect {
+			continue
+		}
+
+		pkg := directPackageFromRequire(r)
+		if pkg.RelPath == "" {
+			continue
+		}
+		if err := validateRelPath(pkg); err != nil {
+			return errors.Newf("%s: require line %q has package suffix %q that does not resolve to package within %s module",
+				modFile, "require "+r.Module.Path+" "+r.Module.Version+" // "+r.ExtraSuffixComment, pkg.RelPath, pkg.Module.Path)
+		}
+	}
+	return nil
+}
+
+// ConsistencyCheck checks if module line, direct require and package meta of bingo enhanced module file are consistent:
+// module line is "_" with bingo meta comment, there is exactly one direct require of valid module path and its
+// package suffix resolves to package within that module. It combines VerifyMeta and ValidateModFile and returns all
+// found problems at once.
+func ConsistencyCheck(modFile string) (err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	errs := merrors.New()
+	if name, _ := mf.Module(); name != "_" {
+		errs.Add(errors.Newf("%s: module line has path %q, expected \"_\" as set by bingo", modFile, name))
+	}
+	errs.Add(VerifyMeta(modFile), ValidateModFile(modFile))
+
+	// Direct requires are checked as they are, as reading direct packages fails on package suffix outside of the
+	// module, which is already reported by VerifyMeta.
+	var direct int
+	for _, r := range mf.RequireDirectives() {
+		if r.Indirect {
+			continue
+		}
+		direct++
+		if err := ValidateModulePath(r.Module.Path); err != nil {
+			errs.Add(errors.Wrap(err, modFile))
+		}
+	}
+	if direct == 0 {
+		errs.Add(errors.Wrap(ErrNoDirectPackage, modFile))
+	}
+	return errs.Err()
+}
+
+// ValidateModFile checks bingo enhanced module file for problems typically caused by manual edits or failed gets:
+// duplicated direct requires, more than one direct require, requires without version, direct requires with v0.0.0
+// placeholder version and requires of package paths instead of module paths (detected using the sum file). All found
+// problems are returned at once.
+func ValidateModFile(modFile string) (err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	errs := merrors.New()
+	direct := map[string]struct{}{}
+	for _, r := range mf.RequireDirectives() {
+		if r.Module.Version == "" {
+			errs.Add(errors.Newf("%s: require of %s module has no version", modFile, r.Module.Path))
+		}
+		if r.Indirect {
+			continue
+		}
+		if r.Module.Version == "v0.0.0" {
+			errs.Add(errors.Newf("%s: direct require of %s module has placeholder version v0.0.0, most likely left by failed version resolution; run bingo get %s again to fix the pin", modFile, r.Module.Path, directPackageFromRequire(r).Path()))
+		}
+		if _, ok := direct[r.Module.Path]; ok {
+			errs.Add(errors.Newf("%s: duplicated direct require of %s module", modFile, r.Module.Path))
+			continue
+		}
+		direct[r.Module.Path] = struct{}{}
+	}
+	if len(direct) > 1 {
+		errs.Add(errors.Newf("%s: expected one direct require, found %d different ones", modFile, len(direct)))
+	}
+	sums, err := ModSumEntries(modFile)
+	if err != nil {
+		errs.Add(errors.Wrapf(err, "%s: sum file", modFile))
+	}
+	for _, r := range mf.RequireDirectives() {
+		if r.Indirect {
+			continue
+		}
+		if err := packagePathRequireErr(modFile, directPackageFromRequire(r), sums); err != nil {
+			errs.Add(err)
+		}
+	}
+	if hasMisplacedIndirect(mf.RequireDirectives()) {
+		errs.Add(errors.Newf("%s: the only require of %s module is marked as indirect; run bingo get again to fix the pin", modFile, mf.RequireDirectives()[0].Module.Path))
+	}
+	return errs.Err()
+}
+
+// HasMisplacedIndirect returns true if the only require of the given module file is marked as indirect, which means
+// bingo pin was most likely corrupted, e.g. by go mod tidy. It only detects the problem; see ParseDirectConfig
+// AllowIndirectFallback for reading such pin anyway.
+func HasMisplacedIndirect(modFile string) (_ bool, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	return hasMisplacedIndirect(mf.RequireDirectives()), nil
+}
+
+func hasMisplacedIndirect(reqs []mod.RequireDirective) bool {
+	return len(reqs) == 1 && reqs[0].Indirect
+}
+
+// ModAllRequires returns modules and versions of all requires, direct and indirect, from any module file in the order
+// of require directives, e.g. to know the whole dependency closure recorded in the pin for vendoring. Use
+// ModDirectPackage for the direct package to build. Module file is not modified.
+func ModAllRequires(modFile string) (mods []module.Version, err error) {
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	for _, r := range mf.RequireDirectives() {
+		mods = append(mods, r.Module)
+	}
+	return mods, nil
+}
+
+// ModIndirectModules return the all indirect mod from any module file.
+func ModIndirectModules(modFile string) (mods []module.Version, err error) {
+	m, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, m.Close, "close")
+
+	for _, r := range m.RequireDirectives() {
+		if !r.Indirect {
+			continue
+		}
+
+		mods = append(mods, r.Module)
+	}
+	return mods, nil
+}
+
+// ModReplaceDirectives return all replace directives from any module file.
+func ModReplaceDirectives(modFile string) (_ []mod.ReplaceDirective, err error) {
+	m, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, m.Close, "close")
+
+	return m.ReplaceDirectives(), nil
+}
+
+// OfflineBuildArgs returns go build arguments for building the given package in the root directory of its module
+// checked out locally, e.g. local replace target (see ModLocalReplacePath). Package is referenced by directory, not by
+// path and version, so nothing has to be resolved. See OfflineBuild.
+func OfflineBuildArgs(pkg Package, out string) []string {
+	return runner.BuildArgs("", offlineBuildTarget(pkg), out, append([]string{"-mod=mod"}, pkg.BuildFlags...)...)
+}
+
+func offlineBuildTarget(pkg Package) string {
+	if rel := path.Clean(toSlash(pkg.RelPath)); rel != "." {
+		return "./" + rel
+	}
+	return "."
+}
+
+// OfflineBuild builds the given package from its module checked out in localPath, without network access: module proxy
+// is disabled, so all dependencies have to be in the module cache already, e.g. for air-gapped builds.
+func OfflineBuild(ctx context.Context, r *runner.Runner, pkg Package, localPath, out string) error {
+	envs := append(envars.EnvSlice{}, pkg.BuildEnvs...)
+	envs.Set("GOPROXY=off")
+	return r.With(ctx, "", localPath, envs).Build(offlineBuildTarget(pkg), out, append([]string{"-mod=mod"}, pkg.BuildFlags...)...)
+}
+
+// RepairComments rewrites the module line and require comments of the given module file into the canonical form bingo
+// writes, e.g. "//cmd/foo  CGO_ENABLED=1" into "// cmd/foo CGO_ENABLED=1", for files with comments edited by hand.
+// Comment elements are kept as is, so their meaning is preserved. It's a no-op for files with canonical comments.
+func RepairComments(modFile string) (err error) {
+	f, err := mod.OpenFile(modFile)
+	if err != nil {
+		return err
+	}
+	defer errcapture.Do(&err, f.Close, "close")
+
+	// Comments are repaired in memory and written at once, only if any changed, so canonical files are not touched.
+	orig := &bytes.Buffer{}
+	if _, err := f.WriteTo(orig); err != nil {
+		return err
+	}
+	m, err := mod.Parse(modFile, bytes.NewReader(orig.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	if p, comment := m.Module(); p != "" {
+		var elems []string
+		for _, c := range strings.Split(comment, moduleCommentSep) {
+			if c = strings.Join(strings.Fields(c), " "); c != "" {
+				elems = append(elems, c)
+			}
+		}
+		if err := m.SetModule(p, strings.Join(elems, moduleCommentSep)); err != nil {
+			return err
+		}
+	}
+	for _, r := range m.RequireDirectives() {
+		if err := m.SetRequireComment(r.Module.Path, strings.Join(strings.Fields(r.ExtraSuffixComment), " ")); err != nil {
+			return err
+		}
+	}
+
+	repaired := &bytes.Buffer{}
+	if _, err := m.WriteTo(repaired); err != nil {
+		return err
+	}
+	if bytes.Equal(orig.Bytes(), repaired.Bytes()) {
+		return nil
+	}
+	return f.SetContent(repaired.Bytes())
+}
+
+// InstallBinaryPath returns the path bingo get installs the binary of the given package to: "<name>-<version>" in the
+// gobin directory. Name is the tool name, usually taken from the module file name (see NameFromModFile), so custom
+// names set with bingo get -n are respected. If empty, the default name for the package path is used, skipping the
+// major version suffix (see NameFromPackagePath).
+func InstallBinaryPath(gobin, name string, p Package) string {
+	if name == "" {
+		name = NameFromPackagePath(p.Path())
+	}
+	return filepath.Join(gobin, fmt.Sprintf("%s-%s", name, p.Module.Version))
+}
+
+// IsBinaryStale returns true if the binary installed from the given module file needs to be built again: it does not
+// exist, it's older than the module file or, if it's named "<name>-<version>" as installed by bingo get, the version
+// is not the pinned one. Symlinks are followed, so e.g. the linked "<name>" binary can be checked too.
+func IsBinaryStale(modFile, binaryPath string) (bool, error) {
+	bi, err := os.Stat(binaryPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	mi, err := os.Stat(modFile)
+	if err != nil {
+		return false, err
+	}
+	if bi.ModTime().Before(mi.ModTime()) {
+		return true, nil
+	}
+
+	name, _ := NameFromModFile(modFile)
+	base := strings.TrimSuffix(filepath.Base(binaryPath), ".exe")
+	if !strings.HasPrefix(base, name+"-") {
+		return false, nil
+	}
+	pkg, err := ModDirectPackage(modFile)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimPrefix(base, name+"-") != pkg.Module.Version, nil
+}
+
+// ModLocalReplacePath returns the local directory the given module is replaced with in the module file, if any.
+// Relative directories are resolved against the module file directory, as go does. Replaces with other module or
+// for other version than the required one are ignored.
+func ModLocalReplacePath(modFile string, modulePath string) (_ string, ok bool, err error) {
+	m, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return "", false, err
+	}
+	defer errcapture.Do(&err, m.Close, "close")
+
+	var version string
+	for _, r := range m.RequireDirectives() {
+		if r.Module.Path == modulePath {
+			version = r.Module.Version
+			break
+		}
+	}
+
+	for _, r := range m.ReplaceDirectives() {
+		if r.Old.Path != modulePath || (r.Old.Version != "" && r.Old.Version != version) {
+			continue
+		}
+		if !r.IsLocal() {
+			return "", false, nil
+		}
+		if filepath.IsAbs(r.New.Path) {
+			return r.New.Path, true, nil
+		}
+		return filepath.Join(filepath.Dir(modFile), r.New.Path), true, nil
+	}
+	return "", false, nil
+}
+
+// ExpandReplacePaths returns content of the given module file with ${VAR} and $VAR references in local replace
+// directive paths expanded using the given env lookup, e.g. "replace x => ${WORKSPACE}/x" for CI with checkouts in
+// machine specific locations. Go does not expand those, so such module file can't be parsed as is. Other replaces and
+// the rest of the content are kept untouched. Error is returned if any referenced variable is empty or the expanded
+// path is not a local directory path. Module file is not modified.
+func ExpandReplacePaths(modFile string, env func(string) string) ([]byte, error) {
+	b, err := os.ReadFile(modFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read")
+	}
+
+	lines := strings.Split(string(b), "\n")
+	inBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inBlock && trimmed == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(trimmed, "replace") && strings.TrimSpace(strings.TrimPrefix(trimmed, "replace")) == "(":
+			inBlock = true
+			continue
+		case !inBlock && !strings.HasPrefix(strings.Join(strings.Fields(trimmed), " "), "replace "):
+			continue
+		}
+
+		arrow := strings.Index(line, "=>")
+		if arrow < 0 {
+			continue
+		}
+		rest := line[arrow+2:]
+		code := rest
+		if c := strings.Index(rest, "//"); c >= 0 {
+			code = rest[:c]
+		}
+		f := strings.Fields(code)
+		if len(f) != 1 || !strings.Contains(f[0], "$") {
+			// Replace with other module or without variables.
+			continue
+		}
+
+		var unset []string
+		expanded := os.Expand(f[0], func(name string) string {
+			v := env(name)
+			if v == "" {
+				unset = append(unset, name)
+			}
+			return v
+		})
+		if len(unset) > 0 {
+			return nil, errors.Newf("%s:%d: replace path %s references empty variables %v", modFile, i+1, f[0], unset)
+		}
+		if !(mod.ReplaceDirective{New: module.Version{Path: expanded}}).IsLocal() {
+			return nil, errors.Newf("%s:%d: replace path %s expanded to %s, which is not a local directory path", modFile, i+1, f[0], expanded)
+		}
+		lines[i] = line[:arrow+2] + strings.Replace(rest, f[0], expanded, 1)
+	}
+
+	out := []byte(strings.Join(lines, "\n"))
+	if _, err := mod.Parse(modFile, bytes.NewReader(out)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ModRetractDirectives return all retract directives from any module file. For bingo enhanced module files those are
+// retractions of the tool module, fetched together with the pinned version.
+func ModRetractDirectives(modFile string) (_ []mod.RetractDirective, err error) {
+	m, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, m.Close, "close")
+
+	return m.RetractDirectives(), nil
+}
+
+// ModExcludeDirectives return all exclude directives from any module file.
+func ModExcludeDirectives(modFile string) (_ []mod.ExcludeDirective, err error) {
+	m, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return nil, err
+	}
+	defer errcapture.Do(&err, m.Close, "close")
+
+	return m.ExcludeDirectives(), nil
+}
+
+// PackageVersionRenderable is used in variables.go. Modify with care.
+type PackageVersionRenderable struct {
+	Version string
+	ModFile string
+}
+
+// PackageRenderable is used in variables.go. Modify with care.
+type PackageRenderable struct {
+	Name        string
+	ModPath     string
+	PackagePath string
+	EnvVarName  string
+	Versions    []PackageVersionRenderable
+
+	BuildFlags   []string
+	BuildEnvVars []string
+}
+
+func (p PackageRenderable) ToPackages() []Package {
+	ret := make([]Package, 0, len(p.Versions))
+	// Import paths always use forward slashes, no matter the OS.
+	relPath := strings.TrimPrefix(strings.TrimPrefix(p.PackagePath, p.ModPath), "/")
+	for _, v := range p.Versions {
+
+		ret = append(ret, Package{
+			Module: module.Version{
+				Version: v.Version,
+				Path:    p.ModPath,
+			},
+			RelPath: relPath,
+		})
+	}
+	return ret
+}
+
+type PackageRenderables []PackageRenderable
+
+func (pkgs PackageRenderables) PrintTab(target string, w io.Writer) error {
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 1, 8, 1, '\t', tabwriter.AlignRight)
+	defer func() { _ = tw.Flush() }()
+
+	_, _ = fmt.Fprint(tw, PackageRenderablesPrintHeader)
+	for _, p := range pkgs {
+		if target != "" && p.Name != target {
+			continue
+		}
+		for _, v := range p.Versions {
+			fields := []string{
+				p.Name,
+				p.Name + "-" + v.Version,
+				p.PackagePath + "@" + v.Version,
+				strings.Join(p.BuildEnvVars, " "),
+				strings.Join(p.BuildFlags, " "),
+			}
+			_, _ = fmt.Fprintln(tw, strings.Join(fields, "\t"))
+		}
+		if target != "" {
+			return nil
+		}
+	}
+
+	if target != "" {
+		return errors.Newf("Pinned tool %s not found", target)
+	}
+	return nil
+}
+
+type pinJSON struct {
+	Module        string `json:"module"`
+	SubPackage    string `json:"subpackage"`
+	ImportPath    string `json:"importpath"`
+	Version       string `json:"version"`
+	PseudoVersion bool   `json:"pseudoversion"`
+}
+
+// MarshalPinsJSON writes given packages to w as JSON array of objects with "module", "subpackage", "importpath",
+// "version" and "pseudoversion" fields. Packages are sorted by import path and version, so output is deterministic.
+func MarshalPinsJSON(pkgs []Package, w io.Writer) error {
+	pins := make([]pinJSON, 0, len(pkgs))
+	for _, p := range pkgs {
+		pins = append(pins, pinJSON{
+			Module:        p.Module.Path,
+			SubPackage:    toSlash(p.RelPath),
+			ImportPath:    p.Path(),
+			Version:       p.Module.Version,
+			PseudoVersion: p.IsUntagged(),
+		})
+	}
+	sort.SliceStable(pins, func(i, j int) bool {
+		if pins[i].ImportPath == pins[j].ImportPath {
+			return pins[i].Version < pins[j].Version
+		}
+		return pins[i].ImportPath < pins[j].ImportPath
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pins)
+}
+
+// ValidateNoPseudo returns all packages pinned to pseudo-versions (see IsPseudoVersion) in the given bingo module
+// directory, sorted by module file name, e.g. to fail CI if only tagged releases are allowed. Module files that can't
+// be inspected are skipped; see InspectDir for reporting them.
+func ValidateNoPseudo(dir string) ([]Package, error) {
+	results, err := InspectDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pseudo []Package
+	for _, res := range results {
+		if res.Err == nil && res.HasMeta && res.Package.IsUntagged() {
+			pseudo = append(pseudo, res.Package)
+		}
+	}
+	return pseudo, nil
+}
+
+// PinsDigest returns hex encoded SHA-256 digest of all tool pins in the given bingo module directory (see
+// ListManagedMods), e.g. for CI cache keys or quick comparison of two checkouts. The digest covers tool name, package
+// path, version, build envs and flags of each pin, sorted, so it changes only if any tool has to be installed again.
+// Error is returned if any managed module file has no direct package.
+func PinsDigest(dir string) (string, error) {
+	modFiles, err := ListManagedMods(dir)
+	if err != nil {
+		return "", err
+	}
+
+	pins := make([]string, 0, len(modFiles))
+	for _, f := range modFiles {
+		pkg, err := ModDirectPackage(f)
+		if err != nil {
+			return "", err
+		}
+		name, _ := NameFromModFile(f)
+		pins = append(pins, strings.Join([]string{
+			name, pkg.Path(), pkg.Module.Version, strings.Join(pkg.BuildEnvs, " "), strings.Join(pkg.BuildFlags, " "),
+		}, "\t"))
+	}
+	sort.Strings(pins)
+
+	h := sha256.New()
+	for _, p := range pins {
+		_, _ = h.Write([]byte(p + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// IsSharedMod returns true if the given file is the fake root go.mod shared by all pins in bingo module directory
+// (see FakeRootModFileName), not a module file of a tool pin. Only the file name is checked.
+func IsSharedMod(modFile string) bool {
+	return filepath.Base(modFile) == FakeRootModFileName
+}
+
+// IsManagedToolMod returns true if the given module file is a tool pin managed by bingo: it is not the shared go.mod,
+// has bingo meta comment in the module line and exactly one direct require. Module file is not modified.
+func IsManagedToolMod(modFile string) (_ bool, err error) {
+	if IsSharedMod(modFile) {
+		return false, nil
+	}
+	mf, err := mod.OpenFileForRead(modFile)
+	if err != nil {
+		return false, err
+	}
+	defer errcapture.Do(&err, mf.Close, "close")
+
+	if _, comment := mf.Module(); !hasMetaComment(comment) {
+		return false, nil
+	}
+	direct := 0
+	for _, r := range mf.RequireDirectives() {
+		if !r.Indirect {
+			direct++
+		}
+	}
+	return direct == 1, nil
+}
+
+// ListManagedMods returns sorted paths of all bingo enhanced module files (with bingo meta comment) in the given
+// directory. Other files, including the fake root go.mod, are skipped.
+func ListManagedMods(dir string) ([]string, error) {
+	modFiles, err := filepath.Glob(filepath.Join(dir, "*.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	var managed []string
+	for _, f := range modFiles {
+		if IsSharedMod(f) {
+			continue
+		}
+		ok, err := ModHasMeta(f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			managed = append(managed, f)
+		}
+	}
+	sort.Strings(managed)
+	return managed, nil
+}
+
+// InspectResult is a result of inspecting single module file in bingo module directory.
+type InspectResult struct {
+	// ModFile is the path to the module file.
+	ModFile string
+	// Package is the first direct package, if module file could be parsed.
+	Package Package
+	// HasMeta is true if module file has bingo meta comment in the module line.
+	HasMeta bool
+	// Incompatible is true if the package version is "+incompatible" one; see IsIncompatible.
+	Incompatible bool
+	// Err is an error found when parsing or validating module file, if any.
+	Err error
+}
+
+// InspectDir inspects all module files in the given bingo module directory except the shared go.mod, sorted by file
+// name. Unlike ListPinnedMainPackages, broken module files are not skipped: parse and validation (see ValidateModFile)
+// errors are returned in the results, so one bad file does not fail the whole listing. Module files are not modified.
+func InspectDir(dir string) ([]InspectResult, error) {
+	modFiles, err := filepath.Glob(filepath.Join(dir, "*.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []InspectResult
+	for _, f := range modFiles {
+		if IsSharedMod(f) {
+			continue
+		}
+		res := InspectResult{ModFile: f}
+		res.Package, res.HasMeta, res.Err = Inspect(f)
+		res.Incompatible = IsIncompatible(res.Package.Module.Version)
+		if res.Err == nil && res.HasMeta {
+			res.Err = ValidateModFile(f)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// OutdatedPin is a result of checking single pin for upgrades, see Outdated.
+type OutdatedPin struct {
+	// ModFile is the path to the module file.
+	ModFile string
+	// Package is the pinned direct package, with the current version.
+	Package Package
+	// Latest is the latest version of the package module.
+	Latest string
+	// Behind is true if the current version is lower than the latest one.
+	Behind bool
+}
+
+// Outdated returns current and latest versions of all pins (bingo enhanced module files) in the given directory, sorted
+// by module file path. The latest version of the module is resolved by the given latestFor function, e.g. using module
+// proxy (see ResolveLatest), once for module pinned in many module files. Versions are compared with CompareVersions.
+func Outdated(dir string, latestFor func(modulePath string) (string, error)) ([]OutdatedPin, error) {
+	modFiles, err := ListManagedMods(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]string{}
+	var pins []OutdatedPin
+	for _, f := range modFiles {
+		pkg, err := ModDirectPackage(f)
+		if err != nil {
+			return nil, errors.Wrap(err, f)
+		}
+		l, ok := latest[pkg.Module.Path]
+		if !ok {
+			if l, err = latestFor(pkg.Module.Path); err != nil {
+				return nil, errors.Wrapf(err, "%s: latest version of %s", f, pkg.Module.Path)
+			}
+			latest[pkg.Module.Path] = l
+		}
+		cmp, err := CompareVersions(pkg.Module.Version, l)
+		if err != nil {
+			return nil, errors.Wrap(err, f)
+		}
+		pins = append(pins, OutdatedPin{ModFile: f, Package: pkg, Latest: l, Behind: cmp < 0})
+	}
+	return pins, nil
+}
+
+// ListPinnedMainPackages lists all bingo pinned binaries (Go main packages) in the same order as seen in the filesystem.
+func ListPinnedMainPackages(logger *log.Logger, modDir string, remMalformed bool) (pkgs PackageRenderables, _ error) {
+	modFiles, err := filepath.Glob(filepath.Join(modDir, "*.mod"))
 	if err != nil {
 		return nil, err
 	}
 ModLoop:
 	for _, f := range modFiles {
-		if filepath.Base(f) == FakeRootModFileName {
+		if IsSharedMod(f) {
 			continue
 		}
 
 		pkg, err := ModDirectPackage(f)
 		if err != nil {
+			if errors.Is(err, ErrPackagePathRequire) {
+				// Not malformed, but can't be installed; keep it, so it can be fixed.
+				logger.Printf("found module file %v with unsupported pin, skipping: %v\n", f, err)
+				continue
+			}
 			if remMalformed {
 				logger.Printf("found malformed module file %v, removing due to error: %v\n", f, err)
 				if err := os.RemoveAll(strings.TrimSuffix(f, ".") + "*"); err != nil {
@@ -387,8 +2056,24 @@ ModLoop:
 			continue
 		}
 
+		goFlags, err := ModGoFlags(f)
+		if err != nil {
+			logger.Printf("found module file %v with invalid goflags comment, skipping: %v\n", f, err)
+			continue
+		}
+		pkg.BuildFlags = append(pkg.BuildFlags, goFlags...)
+
+		if retracts, err := ModRetractDirectives(f); err == nil {
+			if rationale, ok := IsRetracted(pkg.Module.Version, retracts); ok {
+				if rationale != "" {
+					rationale = fmt.Sprintf(" (%s)", rationale)
+				}
+				logger.Printf("WARNING: %s pinned in %s is retracted by its authors%s. Consider pinning other version.\n", pkg.String(), filepath.Base(f), rationale)
+			}
+		}
+
 		name, _ := NameFromModFile(f)
-		varName := strings.ReplaceAll(strings.ReplaceAll(strings.ToUpper(name), ".", "_"), "-", "_")
+		varName := VariableName(name)
 		for i, p := range pkgs {
 			if p.Name == name {
 				pkgs[i].EnvVarName = varName + "_ARRAY"
@@ -421,9 +2106,43 @@ ModLoop:
 			ModPath:     pkg.Module.Path,
 		})
 	}
+	dedupEnvVarNames(pkgs)
 	return pkgs, nil
 }
 
+// VariableName returns environment variable safe name for the tool with the given name, as used in generated
+// Makefile and env files, e.g. "GOLANGCI_LINT" for "golangci-lint".
+func VariableName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// dedupEnvVarNames adds short hash of the tool name to environment variable names shared by different tools
+// (e.g. "foo-bar" and "foo_bar"), so generated variables do not override each other.
+func dedupEnvVarNames(pkgs []PackageRenderable) {
+	byVarName := map[string][]int{}
+	for i, p := range pkgs {
+		byVarName[p.EnvVarName] = append(byVarName[p.EnvVarName], i)
+	}
+	for _, idx := range byVarName {
+		if len(idx) < 2 {
+			continue
+		}
+		for _, i := range idx {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(pkgs[i].Name))
+			pkgs[i].EnvVarName = fmt.Sprintf("%s_%08X", pkgs[i].EnvVarName, h.Sum32())
+		}
+	}
+}
+
 func SortRenderables(pkgs []PackageRenderable) {
 	for _, p := range pkgs {
 		sort.Slice(p.Versions, func(i, j int) bool {
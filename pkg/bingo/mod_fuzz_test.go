@@ -0,0 +1,71 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+//go:build go1.18
+// +build go1.18
+
+package bingo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bwplotka/bingo/pkg/mod"
+)
+
+func FuzzCheckRoundTrip(f *testing.F) {
+	for _, seed := range []string{
+		"module _\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible\n",
+		"module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo\n",
+		"module _\n\ngo 1.17\n\nrequire (\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect\n\tgithub.com/bwplotka/bingo v0.6.0\n)\n\nexclude github.com/bwplotka/bingo v0.5.0\n",
+		"module _\r\n\r\ngo 1.14\r\n\r\nrequire github.com/bwplotka/bingo v0.6.0 // cmd/bingo\r\n",
+		// Empty comments used to panic.
+		"module 0//\nrequire example.com/0 v0.0.0+000000000000",
+		// Package suffix "." used to be dropped on write, turning the next element into package suffix.
+		"module 0\nrequire(\n00000.00000000000000000000 v0.0.0+0000//. 0\n)",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := mod.Parse("fuzz.mod", bytes.NewReader(b))
+		if err != nil {
+			t.Skip()
+		}
+		pkgs, err := directPackages(m, ParseDirectConfig{})
+		if err != nil {
+			t.Skip()
+		}
+		for _, p := range pkgs {
+			if ValidateModulePath(p.Module.Path) != nil {
+				t.Skip()
+			}
+		}
+		if err := CheckRoundTrip("fuzz.mod", bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzModDirectPackage(f *testing.F) {
+	for _, seed := range []string{
+		"module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo\n",
+		"module _\n\ngo 1.14\n\nrequire (\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect\n)\n",
+		"module _\n\nrequire github.com/fatih/faillint v1.5.0 //\n",
+		"module _\n\nrequire github.com/fatih/faillint v1.5.0 //cmd/faillint\n",
+		"module _ //\n\nrequire github.com/fatih/faillint v1.5.0 //  \n",
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+	dir := f.TempDir()
+	f.Fuzz(func(t *testing.T, b []byte) {
+		modFile := filepath.Join(dir, "fuzz.mod")
+		if err := os.WriteFile(modFile, b, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		// Any content is fine as long as it returns error instead of panicking.
+		_, _ = ModDirectPackage(modFile)
+	})
+}
@@ -4,14 +4,21 @@
 package bingo
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/bwplotka/bingo/pkg/mod"
 	"github.com/bwplotka/bingo/pkg/runner"
+	"github.com/efficientgo/core/errors"
 	"github.com/efficientgo/core/testutil"
 	"golang.org/x/mod/module"
 )
@@ -138,6 +145,7 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
 
 		mf, err := OpenModFile(testFile)
 		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, mf.Close()) }()
 
 		testutil.Equals(t, true, mf.IsDirectivesAutoFetchDisabled())
 		testutil.Equals(t, Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}, *mf.DirectPackage())
@@ -171,6 +179,7 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
 
 		mf, err := OpenModFile(testFile)
 		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, mf.Close()) }()
 
 		testutil.Equals(t, false, mf.IsDirectivesAutoFetchDisabled())
 		testutil.Equals(t, Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}, *mf.DirectPackage())
@@ -187,6 +196,7 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus -
 
 		mf, err := OpenModFile(testFile)
 		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, mf.Close()) }()
 
 		testutil.Equals(t, false, mf.IsDirectivesAutoFetchDisabled())
 		testutil.Equals(t, Package{
@@ -207,6 +217,7 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus C
 
 		mf, err := OpenModFile(testFile)
 		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, mf.Close()) }()
 
 		testutil.Equals(t, false, mf.IsDirectivesAutoFetchDisabled())
 		testutil.Equals(t, Package{
@@ -227,6 +238,7 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus C
 
 		mf, err := OpenModFile(testFile)
 		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, mf.Close()) }()
 
 		testutil.Equals(t, false, mf.IsDirectivesAutoFetchDisabled())
 		testutil.Equals(t, Package{
@@ -247,6 +259,7 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // CGO_ENABLED=1 GO
 
 		mf, err := OpenModFile(testFile)
 		testutil.Ok(t, err)
+		defer func() { testutil.Ok(t, mf.Close()) }()
 
 		testutil.Equals(t, false, mf.IsDirectivesAutoFetchDisabled())
 		testutil.Equals(t, Package{
@@ -256,3 +269,2072 @@ require github.com/prometheus/prometheus v2.4.3+incompatible // CGO_ENABLED=1 GO
 		}, *mf.DirectPackage())
 	})
 }
+
+func TestOpenModFile_Idempotent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("file with meta is not rewritten", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test.mod")
+		// Extra new lines would be formatted away on rewrite.
+		content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+
+
+go 1.14
+
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo
+`
+		testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+		for i := 0; i < 2; i++ {
+			mf, err := OpenModFile(testFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, Package{
+				Module:     module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+				RelPath:    "cmd/prometheus",
+				BuildEnvs:  []string{"CGO_ENABLED=1"},
+				BuildFlags: []string{"-tags=yolo"},
+			}, *mf.DirectPackage())
+			testutil.Ok(t, mf.Close())
+			expectContent(t, content, testFile)
+		}
+	})
+	t.Run("file with non canonical package meta is rewritten", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test2.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus  CGO_ENABLED=1
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`), os.ModePerm))
+
+		mf, err := OpenModFile(testFile)
+		testutil.Ok(t, err)
+		testutil.Ok(t, mf.Close())
+		expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1
+`, testFile)
+	})
+}
+
+func TestRenderMinimalMod(t *testing.T) {
+	b, err := RenderMinimalMod(Package{
+		Module:     module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+		RelPath:    "cmd/prometheus",
+		BuildEnvs:  []string{"CGO_ENABLED=1"},
+		BuildFlags: []string{"-tags=yolo"},
+	}, "1.17")
+	testutil.Ok(t, err)
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "prometheus.mod.golden"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, string(golden), string(b))
+
+	pkg, hasMeta, err := InspectReader("prometheus.mod", bytes.NewReader(b))
+	testutil.Ok(t, err)
+	testutil.Assert(t, hasMeta)
+	testutil.Equals(t, "github.com/prometheus/prometheus/cmd/prometheus@v2.4.3+incompatible", pkg.String())
+
+	_, err = RenderMinimalMod(Package{Module: module.Version{Path: "github.com/fatih/faillint"}}, "1.17")
+	testutil.NotOk(t, err)
+	_, err = RenderMinimalMod(Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, "yolo")
+	testutil.NotOk(t, err)
+}
+
+func TestOpenModFile_PreservesReplaceDirectives(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(`module _
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+
+replace (
+	github.com/miekg/dns => github.com/miekg/dns v1.0.4
+	k8s.io/klog => github.com/simonpasquier/klog-gokit v0.1.0
+)
+`), os.ModePerm))
+
+	mf, err := OpenModFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, mf.Close())
+
+	expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+
+replace (
+	github.com/miekg/dns => github.com/miekg/dns v1.0.4
+	k8s.io/klog => github.com/simonpasquier/klog-gokit v0.1.0
+)
+`, testFile)
+
+	replaces, err := ModReplaceDirectives(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []mod.ReplaceDirective{
+		{Old: module.Version{Path: "github.com/miekg/dns"}, New: module.Version{Path: "github.com/miekg/dns", Version: "v1.0.4"}},
+		{Old: module.Version{Path: "k8s.io/klog"}, New: module.Version{Path: "github.com/simonpasquier/klog-gokit", Version: "v0.1.0"}},
+	}, replaces)
+}
+
+func TestOpenModFile_PreservesExcludeDirectives(t *testing.T) {
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+
+exclude (
+	github.com/miekg/dns v1.0.5
+	k8s.io/klog v0.2.0
+)
+`
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(strings.Replace(content, " // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT", "", 1)), os.ModePerm))
+
+	mf, err := OpenModFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, mf.Close())
+	expectContent(t, content, testFile)
+
+	excludes, err := ModExcludeDirectives(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []mod.ExcludeDirective{
+		{Module: module.Version{Path: "github.com/miekg/dns", Version: "v1.0.5"}},
+		{Module: module.Version{Path: "k8s.io/klog", Version: "v0.2.0"}},
+	}, excludes)
+}
+
+func TestModLocalReplacePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+
+replace (
+	github.com/bwplotka/abs => /home/bwplotka/abs
+	github.com/bwplotka/other v1.0.0 => ../other
+	github.com/miekg/dns => github.com/miekg/dns v1.0.4
+	github.com/prometheus/prometheus => ../prometheus
+)
+`), os.ModePerm))
+
+	for _, tcase := range []struct {
+		modulePath   string
+		expectedPath string
+		expectedOk   bool
+	}{
+		{modulePath: "github.com/prometheus/prometheus", expectedPath: filepath.Join(filepath.Dir(tmpDir), "prometheus"), expectedOk: true},
+		{modulePath: "github.com/bwplotka/abs", expectedPath: "/home/bwplotka/abs", expectedOk: true},
+		{modulePath: "github.com/miekg/dns"},
+		// Not required in v1.0.0 version.
+		{modulePath: "github.com/bwplotka/other"},
+		{modulePath: "github.com/bwplotka/not-replaced"},
+	} {
+		t.Run(tcase.modulePath, func(t *testing.T) {
+			p, ok, err := ModLocalReplacePath(testFile, tcase.modulePath)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expectedOk, ok)
+			testutil.Equals(t, tcase.expectedPath, p)
+		})
+	}
+}
+
+func TestOfflineBuildArgs(t *testing.T) {
+	pkg := Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus", BuildFlags: []string{"-tags=netgo"}}
+	testutil.Equals(t, []string{"build", "-o=/bin/prometheus", "-mod=mod", "-tags=netgo", "./cmd/prometheus"}, OfflineBuildArgs(pkg, "/bin/prometheus"))
+
+	pkg = Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}
+	testutil.Equals(t, []string{"build", "-o=/bin/faillint", "-mod=mod", "."}, OfflineBuildArgs(pkg, "/bin/faillint"))
+}
+
+func TestIsBinaryStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	modFile := filepath.Join(tmpDir, "faillint.mod")
+	testutil.Ok(t, os.WriteFile(modFile, []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"), os.ModePerm))
+	pinned := time.Now().Add(-time.Hour)
+	testutil.Ok(t, os.Chtimes(modFile, pinned, pinned))
+
+	bin := filepath.Join(tmpDir, "faillint-v1.5.0")
+	stale, err := IsBinaryStale(modFile, bin)
+	testutil.Ok(t, err)
+	testutil.Assert(t, stale, "missing binary is stale")
+
+	testutil.Ok(t, os.WriteFile(bin, []byte("binary"), os.ModePerm))
+	testutil.Ok(t, os.Chtimes(bin, pinned.Add(-time.Minute), pinned.Add(-time.Minute)))
+	stale, err = IsBinaryStale(modFile, bin)
+	testutil.Ok(t, err)
+	testutil.Assert(t, stale, "binary older than module file is stale")
+
+	testutil.Ok(t, os.Chtimes(bin, pinned.Add(time.Minute), pinned.Add(time.Minute)))
+	stale, err = IsBinaryStale(modFile, bin)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !stale, "binary newer than module file is not stale")
+
+	other := filepath.Join(tmpDir, "faillint-v1.4.0")
+	testutil.Ok(t, os.WriteFile(other, []byte("binary"), os.ModePerm))
+	stale, err = IsBinaryStale(modFile, other)
+	testutil.Ok(t, err)
+	testutil.Assert(t, stale, "binary of other version is stale")
+
+	link := filepath.Join(tmpDir, "faillint")
+	testutil.Ok(t, os.Symlink(bin, link))
+	stale, err = IsBinaryStale(modFile, link)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !stale, "linked binary is not stale")
+}
+
+func TestRepairComments(t *testing.T) {
+	const canonical = "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT // tag: v1.0.0\n\ngo 1.14\n\nrequire (\n\tgithub.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect; hint\n)\n"
+
+	for _, tcase := range []struct {
+		name    string
+		content string
+	}{
+		{name: "canonical", content: canonical},
+		{name: "no space after slashes", content: "module _ //Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT // tag: v1.0.0\n\ngo 1.14\n\nrequire (\n\tgithub.com/prometheus/prometheus v2.4.3+incompatible //cmd/prometheus CGO_ENABLED=1 -tags=yolo\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect; hint\n)\n"},
+		{name: "odd spacing", content: "module _ //   Auto generated by https://github.com/bwplotka/bingo.  DO NOT EDIT  //  tag: v1.0.0 \n\ngo 1.14\n\nrequire (\n\tgithub.com/prometheus/prometheus v2.4.3+incompatible //  cmd/prometheus \tCGO_ENABLED=1   -tags=yolo\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect;   hint\n)\n"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			modFile := filepath.Join(t.TempDir(), "prometheus.mod")
+			testutil.Ok(t, os.WriteFile(modFile, []byte(tcase.content), os.ModePerm))
+			hourAgo := time.Now().Add(-1 * time.Hour).Truncate(time.Second)
+			testutil.Ok(t, os.Chtimes(modFile, hourAgo, hourAgo))
+
+			testutil.Ok(t, RepairComments(modFile))
+			expectContent(t, canonical, modFile)
+
+			// Canonical file must not be rewritten, otherwise installed binaries look stale (see IsBinaryStale).
+			fi, err := os.Stat(modFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.content == canonical, fi.ModTime().Equal(hourAgo))
+
+			pkg, err := ModDirectPackage(modFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, Package{
+				Module:     module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+				RelPath:    "cmd/prometheus",
+				BuildEnvs:  []string{"CGO_ENABLED=1"},
+				BuildFlags: []string{"-tags=yolo"},
+			}, pkg)
+		})
+	}
+}
+
+func TestInstallBinaryPath(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		pkg      Package
+		expected string
+	}{
+		{name: "faillint", pkg: Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, expected: "gobin/faillint-v1.5.0"},
+		{name: "lint", pkg: Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, expected: "gobin/lint-v1.5.0"},
+		{pkg: Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, expected: "gobin/faillint-v1.5.0"},
+		{pkg: Package{Module: module.Version{Path: "github.com/bwplotka/bingo/v2", Version: "v2.0.0"}}, expected: "gobin/bingo-v2.0.0"},
+		{pkg: Package{Module: module.Version{Path: "golang.org/x/tools", Version: "v0.1.5"}, RelPath: "cmd/goimports"}, expected: "gobin/goimports-v0.1.5"},
+	} {
+		t.Run(tcase.expected, func(t *testing.T) {
+			testutil.Equals(t, filepath.Join(strings.Split(tcase.expected, "/")...), InstallBinaryPath("gobin", tcase.name, tcase.pkg))
+		})
+	}
+}
+
+func TestAddMetaToModFor(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(`module _
+
+go 1.14
+
+require (
+	github.com/bwplotka/server v1.2.0 // cmd/server
+	github.com/bwplotka/plugin v1.2.0 // CGO_ENABLED=1 -tags=yolo
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`), os.ModePerm))
+
+	testutil.Ok(t, AddMetaToModFor(testFile, "github.com/bwplotka/plugin", "cmd/protoc-gen-plugin"))
+	expected := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/bwplotka/server v1.2.0 // cmd/server
+	github.com/bwplotka/plugin v1.2.0 // cmd/protoc-gen-plugin CGO_ENABLED=1 -tags=yolo
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`
+	expectContent(t, expected, testFile)
+
+	// Noop if already there.
+	testutil.Ok(t, AddMetaToModFor(testFile, "github.com/bwplotka/plugin", "cmd/protoc-gen-plugin"))
+	expectContent(t, expected, testFile)
+
+	err := AddMetaToModFor(testFile, "github.com/efficientgo/core", "")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, "no direct require of github.com/efficientgo/core module found in "+testFile, err.Error())
+	expectContent(t, expected, testFile)
+
+	testutil.Ok(t, AddMetaToModFor(testFile, "github.com/bwplotka/server", ""))
+	expectContent(t, strings.Replace(expected, " // cmd/server", "", 1), testFile)
+}
+
+func TestAddMetaToModFor_PinnedAt(t *testing.T) {
+	defer func(write bool) { WritePinnedAt = write }(WritePinnedAt)
+	defer func(f func() time.Time) { now = f }(now)
+
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n\nrequire github.com/bwplotka/server v1.2.0\n"), os.ModePerm))
+
+	// Disabled by default.
+	testutil.Ok(t, AddMetaToModFor(testFile, "github.com/bwplotka/server", "cmd/server"))
+	_, ok, err := ModPinnedAt(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok)
+
+	WritePinnedAt = true
+	for _, pinned := range []time.Time{
+		time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC),
+		// Previous one is replaced, time is always written in UTC.
+		time.Date(2021, 5, 6, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60)),
+	} {
+		now = func() time.Time { return pinned }
+		testutil.Ok(t, AddMetaToModFor(testFile, "github.com/bwplotka/server", "cmd/server"))
+		got, ok, err := ModPinnedAt(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, ok)
+		testutil.Assert(t, pinned.Equal(got), "%v != %v", pinned, got)
+	}
+	expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT // pinned 2021-05-06T10:30:00Z
+
+go 1.14
+
+require github.com/bwplotka/server v1.2.0 // cmd/server
+`, testFile)
+
+	// Informational only.
+	pkg, err := ModDirectPackage(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "github.com/bwplotka/server/cmd/server@v1.2.0", pkg.String())
+	testutil.Ok(t, ConsistencyCheck(testFile))
+
+	testutil.Ok(t, RemoveMetaFromMod(testFile))
+	expectContent(t, "module _\n\ngo 1.14\n\nrequire github.com/bwplotka/server v1.2.0\n", testFile)
+
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _ // pinned yesterday\n"), os.ModePerm))
+	_, _, err = ModPinnedAt(testFile)
+	testutil.NotOk(t, err)
+}
+
+func TestSetModTag(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/efficientgo/tools/copyright v0.0.0-20210201224146-3d78f4d30648
+`
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+	_, ok, err := ModTag(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok)
+
+	testutil.Ok(t, SetModTag(testFile, "v0.1.0"))
+	testutil.Ok(t, SetModTag(testFile, "v0.2.0"))
+	expectContent(t, strings.Replace(content, "DO NOT EDIT", "DO NOT EDIT // tag: v0.2.0", 1), testFile)
+
+	tag, ok, err := ModTag(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, ok)
+	testutil.Equals(t, "v0.2.0", tag)
+
+	pkg, err := ModDirectPackage(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "v0.0.0-20210201224146-3d78f4d30648", pkg.Module.Version)
+
+	testutil.NotOk(t, SetModTag(testFile, "latest"))
+	testutil.Ok(t, SetModTag(testFile, ""))
+	expectContent(t, content, testFile)
+
+	testutil.Ok(t, SetModTag(testFile, "v0.2.0"))
+	testutil.Ok(t, RemoveMetaFromMod(testFile))
+	expectContent(t, strings.Replace(content, " // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT", "", 1), testFile)
+}
+
+func TestSetModGroup(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/fatih/faillint v1.5.0
+`
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+	group, ok, err := ModGroup(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok)
+	testutil.Equals(t, "", group)
+
+	testutil.Ok(t, SetModGroup(testFile, "codegen"))
+	testutil.Ok(t, SetModGroup(testFile, "linters"))
+	grouped := strings.Replace(content, "DO NOT EDIT", "DO NOT EDIT // group: linters", 1)
+	expectContent(t, grouped, testFile)
+
+	// Group survives reformatting and regenerating meta.
+	testutil.Ok(t, RepairComments(testFile))
+	testutil.Ok(t, AddMetaToModFor(testFile, "github.com/fatih/faillint", ""))
+	mf, err := OpenModFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, mf.Close())
+	expectContent(t, grouped, testFile)
+
+	group, ok, err = ModGroup(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, ok)
+	testutil.Equals(t, "linters", group)
+
+	testutil.NotOk(t, SetModGroup(testFile, "my linters"))
+	testutil.NotOk(t, SetModGroup(testFile, "// yolo"))
+	testutil.Ok(t, SetModGroup(testFile, ""))
+	expectContent(t, content, testFile)
+
+	testutil.Ok(t, SetModGroup(testFile, "linters"))
+	testutil.Ok(t, RemoveMetaFromMod(testFile))
+	expectContent(t, strings.Replace(content, " // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT", "", 1), testFile)
+}
+
+func TestSetVersion(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo
+`), os.ModePerm))
+
+	testutil.Ok(t, SetVersion(testFile, "github.com/prometheus/prometheus", "v2.5.0+incompatible"))
+	expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.5.0+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo
+`, testFile)
+
+	err := SetVersion(testFile, "github.com/prometheus/alertmanager", "v0.21.0")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, "no direct require of github.com/prometheus/alertmanager module found in "+testFile, err.Error())
+}
+
+func TestDiffVersion(t *testing.T) {
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+	old, changed, err := DiffVersion(testFile, "v2.5.0+incompatible")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "v2.4.3+incompatible", old)
+	testutil.Assert(t, changed)
+
+	old, changed, err = DiffVersion(testFile, "v2.4.3+incompatible")
+	testutil.Ok(t, err)
+	testutil.Equals(t, "v2.4.3+incompatible", old)
+	testutil.Assert(t, !changed)
+
+	expectContent(t, content, testFile)
+}
+
+func TestRenameModulePath(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/golang/tools v0.1.5 // cmd/goimports CGO_ENABLED=0 -tags=yolo
+`), os.ModePerm))
+
+	t.Run("moved repository", func(t *testing.T) {
+		testutil.Ok(t, RenameModulePath(testFile, "github.com/golang/tools", "golang.org/x/tools"))
+		expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require golang.org/x/tools v0.1.5 // cmd/goimports CGO_ENABLED=0 -tags=yolo
+`, testFile)
+	})
+	t.Run("package moved to its own module", func(t *testing.T) {
+		testutil.Ok(t, RenameModulePath(testFile, "golang.org/x/tools", "golang.org/x/tools/cmd/goimports"))
+		expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require golang.org/x/tools/cmd/goimports v0.1.5 // CGO_ENABLED=0 -tags=yolo
+`, testFile)
+	})
+	t.Run("not found", func(t *testing.T) {
+		err := RenameModulePath(testFile, "golang.org/x/tools", "golang.org/x/tools/v2")
+		testutil.NotOk(t, err)
+		testutil.Equals(t, "no direct require of golang.org/x/tools module found in "+testFile, err.Error())
+	})
+}
+
+func TestUpgradePreservingSubPackage(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/bwplotka/tools v1.5.0 // cmd/server CGO_ENABLED=0
+`), os.ModePerm))
+
+	for _, tcase := range []struct {
+		name            string
+		modulePath      string
+		version         string
+		expectedPkg     string
+		expectedRequire string
+		expectedErr     string
+	}{
+		{
+			name: "major version upgrade", modulePath: "github.com/bwplotka/tools/v2", version: "v2.0.0",
+			expectedRequire: "github.com/bwplotka/tools/v2 v2.0.0 // cmd/server CGO_ENABLED=0",
+		},
+		{
+			name: "version not matching module path", modulePath: "github.com/bwplotka/tools/v2", version: "v3.0.0",
+			expectedErr: "github.com/bwplotka/tools/v2@v3.0.0: invalid version: should be v2, not v3",
+		},
+		{
+			name: "major version downgrade", modulePath: "github.com/bwplotka/tools", version: "v1.6.0",
+			expectedRequire: "github.com/bwplotka/tools v1.6.0 // cmd/server CGO_ENABLED=0",
+		},
+		{
+			name: "nested module without the package", modulePath: "github.com/bwplotka/tools/cmd/other", version: "v0.1.0",
+			expectedErr: "package github.com/bwplotka/tools/cmd/server does not resolve under the new module path github.com/bwplotka/tools/cmd/other",
+		},
+		{
+			name: "package moved to its own module", modulePath: "github.com/bwplotka/tools/cmd/server", version: "v0.1.0",
+			expectedRequire: "github.com/bwplotka/tools/cmd/server v0.1.0 // CGO_ENABLED=0",
+		},
+		{
+			name: "package moved back", modulePath: "github.com/bwplotka/tools", version: "v1.7.0",
+			expectedRequire: "github.com/bwplotka/tools v1.7.0 // cmd/server CGO_ENABLED=0",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			before, err := os.ReadFile(testFile)
+			testutil.Ok(t, err)
+
+			err = UpgradePreservingSubPackage(testFile, tcase.modulePath, tcase.version)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, testFile+": "+tcase.expectedErr, err.Error())
+				expectContent(t, string(before), testFile)
+				return
+			}
+			testutil.Ok(t, err)
+			expectContent(t, "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire "+tcase.expectedRequire+"\n", testFile)
+		})
+	}
+}
+
+func TestPackage_Equal(t *testing.T) {
+	pkg := Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}
+
+	for _, other := range []Package{
+		pkg,
+		{Module: module.Version{Version: "v2.4.3+incompatible"}, RelPath: "github.com/prometheus/prometheus/cmd/prometheus"},
+		{Module: module.Version{Path: "github.com/prometheus/prometheus/cmd/prometheus", Version: "v2.4.3+incompatible"}},
+		{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "./cmd/prometheus/", BuildEnvs: []string{"CGO_ENABLED=1"}},
+	} {
+		testutil.Assert(t, pkg.Equal(other), "%v", other)
+		testutil.Assert(t, other.Equal(pkg), "%v", other)
+	}
+	for _, other := range []Package{
+		{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.5.0+incompatible"}, RelPath: "cmd/prometheus"},
+		{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/promtool"},
+		{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}},
+	} {
+		testutil.Assert(t, !pkg.Equal(other), "%v", other)
+	}
+}
+
+func TestModFile_SetDirectRequireOutsideOfModule(t *testing.T) {
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`
+	mf, err := ParseModFile("test.mod", strings.NewReader(content))
+	testutil.Ok(t, err)
+
+	for _, relPath := range []string{"../alertmanager/cmd/amtool", "..", "cmd/../../other", "/cmd/prometheus"} {
+		err = mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: relPath})
+		testutil.NotOk(t, err)
+	}
+	testutil.Equals(t, `package suffix "/cmd/prometheus" points outside of github.com/prometheus/prometheus module (package path github.com/prometheus/prometheus/cmd/prometheus)`, err.Error())
+
+	err = mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "../alertmanager/cmd/amtool"})
+	testutil.Equals(t, `package suffix "../alertmanager/cmd/amtool" points outside of github.com/prometheus/prometheus module (package path github.com/prometheus/alertmanager/cmd/amtool)`, err.Error())
+
+	// Nothing changed.
+	b := &bytes.Buffer{}
+	_, err = mf.WriteTo(b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, content, b.String())
+	testutil.Ok(t, mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/../cmd/promtool"}))
+}
+
+func TestModFile_ForwardSlashes(t *testing.T) {
+	mf, err := ParseModFile("test.mod", strings.NewReader(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd\prometheus
+`))
+	testutil.Ok(t, err)
+	testutil.Equals(t, "cmd/prometheus", mf.DirectPackage().RelPath)
+	testutil.Equals(t, "github.com/prometheus/prometheus/cmd/prometheus", mf.DirectPackage().Path())
+
+	testutil.Ok(t, mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: `cmd\promtool`}))
+	b := &bytes.Buffer{}
+	_, err = mf.WriteTo(b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/promtool
+`, b.String())
+
+	testutil.Equals(t, []Package{{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/promtool"}}, PackageRenderable{
+		Versions:    []PackageVersionRenderable{{Version: "v2.4.3+incompatible"}},
+		ModPath:     "github.com/prometheus/prometheus",
+		PackagePath: "github.com/prometheus/prometheus/cmd/promtool",
+	}.ToPackages())
+}
+
+func TestOpenModFile_TidyFileOnlyGetsMeta(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	tidy := `module _
+
+go 1.14
+
+// Comment 1.
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+
+replace (
+	// Ridiculous but Prometheus v2.4.3 did not have Go modules
+	github.com/Azure/azure-sdk-for-go => github.com/Azure/azure-sdk-for-go v5.0.0-beta.0.20161028183111-bd73d950fa44+incompatible
+	k8s.io/klog => github.com/simonpasquier/klog-gokit v0.1.0
+)
+
+exclude github.com/miekg/dns v1.0.5
+
+// Wrongly formatted.
+retract v1.0.0
+`
+	testutil.Ok(t, os.WriteFile(testFile, []byte(tidy), os.ModePerm))
+
+	mf, err := OpenModFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, mf.Close())
+
+	// Bingo meta comment should be the only difference.
+	expectContent(t, strings.Replace(tidy, "module _\n", "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n", 1), testFile)
+}
+
+func TestParseModFile(t *testing.T) {
+	mf, err := ParseModFile("test.mod", strings.NewReader(`module _
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`))
+	testutil.Ok(t, err)
+	testutil.Equals(t, Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}, *mf.DirectPackage())
+
+	testutil.Ok(t, mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.5.0+incompatible"}, RelPath: "cmd/promtool"}))
+
+	b := &bytes.Buffer{}
+	_, err = mf.WriteTo(b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.5.0+incompatible // cmd/promtool
+`, b.String())
+	testutil.Ok(t, mf.Close())
+}
+
+func TestModFile_SetLogger(t *testing.T) {
+	mf, err := ParseModFile("test.mod", strings.NewReader(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`))
+	testutil.Ok(t, err)
+
+	b := &bytes.Buffer{}
+	mf.SetLogger(log.New(b, "", 0))
+	testutil.Ok(t, mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.5.0+incompatible"}, RelPath: "cmd/prometheus"}))
+	testutil.Equals(t, "test.mod: setting direct require to github.com/prometheus/prometheus/cmd/prometheus@v2.5.0+incompatible (previously github.com/prometheus/prometheus/cmd/prometheus@v2.4.3+incompatible)\n", b.String())
+}
+
+func TestModDirectPackages(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("multiple direct requires", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test.mod")
+		content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/bwplotka/server v1.2.0 // cmd/server
+	github.com/bwplotka/plugin v1.2.0 // cmd/protoc-gen-plugin CGO_ENABLED=1
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+	github.com/bwplotka/other v0.1.0
+)
+`
+		testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+		pkgs, err := ModDirectPackages(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, []Package{
+			{Module: module.Version{Path: "github.com/bwplotka/server", Version: "v1.2.0"}, RelPath: "cmd/server"},
+			{Module: module.Version{Path: "github.com/bwplotka/plugin", Version: "v1.2.0"}, RelPath: "cmd/protoc-gen-plugin", BuildEnvs: []string{"CGO_ENABLED=1"}},
+			{Module: module.Version{Path: "github.com/bwplotka/other", Version: "v0.1.0"}},
+		}, pkgs)
+
+		pkg, err := ModDirectPackage(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, pkgs[0], pkg)
+
+		// Reads should not modify the file.
+		expectContent(t, content, testFile)
+	})
+	t.Run("no direct requires", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test2.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/efficientgo/core v1.0.0-rc.0 // indirect
+`), os.ModePerm))
+
+		_, err := ModDirectPackages(testFile)
+		testutil.NotOk(t, err)
+		testutil.Assert(t, errors.Is(err, ErrNoDirectPackage), "expected ErrNoDirectPackage, got %v", err)
+		_, err = ModDirectPackage(testFile)
+		testutil.NotOk(t, err)
+		testutil.Assert(t, errors.Is(err, ErrNoDirectPackage), "expected ErrNoDirectPackage, got %v", err)
+		_, err = ModDirectComments(testFile)
+		testutil.NotOk(t, err)
+		testutil.Assert(t, errors.Is(err, ErrNoDirectPackage), "expected ErrNoDirectPackage, got %v", err)
+	})
+	t.Run("package suffix outside of module", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test3.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // ../cmd/prometheus
+`), os.ModePerm))
+
+		// Such pin could not be written back, so it's rejected when read too.
+		_, err := ModDirectPackages(testFile)
+		testutil.NotOk(t, err)
+		testutil.Equals(t, testFile+`: package suffix "../cmd/prometheus" points outside of github.com/prometheus/prometheus module (package path github.com/prometheus/cmd/prometheus)`, err.Error())
+	})
+	t.Run("indirect only with fallback", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test4.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // indirect
+`), os.ModePerm))
+
+		_, err := ParseDirect(testFile, ParseDirectConfig{})
+		testutil.NotOk(t, err)
+		testutil.Assert(t, errors.Is(err, ErrNoDirectPackage), "expected ErrNoDirectPackage, got %v", err)
+
+		b := &bytes.Buffer{}
+		pkgs, err := ParseDirect(testFile, ParseDirectConfig{AllowIndirectFallback: true, Logger: log.New(b, "", 0)})
+		testutil.Ok(t, err)
+		testutil.Equals(t, []Package{{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}}}, pkgs)
+		testutil.Equals(t, "warning: "+testFile+": the only require of github.com/prometheus/prometheus module is marked as indirect; treating it as direct\n", b.String())
+	})
+	t.Run("from filesystem", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"pins/prometheus.mod": {Data: []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`)},
+			"pins/empty.mod": {Data: []byte("module _\n")},
+		}
+
+		pkg, err := ModDirectPackageFS(fsys, "pins/prometheus.mod")
+		testutil.Ok(t, err)
+		testutil.Equals(t, Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}, pkg)
+
+		_, err = ModDirectPackageFS(fsys, "pins/empty.mod")
+		testutil.NotOk(t, err)
+		testutil.Assert(t, errors.Is(err, ErrNoDirectPackage), "expected ErrNoDirectPackage, got %v", err)
+		testutil.Equals(t, "pins/empty.mod: no direct package found; empty module?", err.Error())
+
+		_, err = ModDirectPackageFS(fsys, "pins/nope.mod")
+		testutil.NotOk(t, err)
+	})
+	t.Run("broken lines", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test5.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14 yolo
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`), os.ModePerm))
+
+		_, err := ModDirectPackage(testFile)
+		testutil.NotOk(t, err)
+
+		pkg, errs := ModDirectPackageLenient(testFile)
+		testutil.Equals(t, 1, len(errs))
+		testutil.Equals(t, testFile+":3: go directive expects exactly one argument", errs[0].Error())
+		testutil.Equals(t, Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}, pkg)
+	})
+	t.Run("proxy", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test6.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/bwplotka/internal v1.0.0 // cmd/internal GOPROXY=https://proxy.example.com,direct CGO_ENABLED=0
+`), os.ModePerm))
+
+		proxy, err := ModProxy(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, "https://proxy.example.com,direct", proxy)
+
+		testFile = filepath.Join(tmpDir, "test7.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/bwplotka/public v1.0.0 // cmd/public CGO_ENABLED=0
+`), os.ModePerm))
+
+		proxy, err = ModProxy(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, "", proxy)
+	})
+	t.Run("inspect", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test8.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`), os.ModePerm))
+
+		pkg, hasMeta, err := Inspect(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, hasMeta)
+		testutil.Equals(t, Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"}, pkg)
+
+		testFile = filepath.Join(tmpDir, "test9.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible\n"), os.ModePerm))
+
+		pkg, hasMeta, err = Inspect(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, !hasMeta)
+		testutil.Equals(t, "github.com/prometheus/prometheus@v2.4.3+incompatible", pkg.String())
+		testutil.Assert(t, !pkg.IsUntagged())
+
+		testFile = filepath.Join(tmpDir, "test10.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n\nrequire github.com/bwplotka/bingo v0.0.0-20210220032951-036812b2e83c\n"), os.ModePerm))
+
+		pkg, _, err = Inspect(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, pkg.IsUntagged())
+
+		pkg, hasMeta, err = InspectReader("<stdin>", strings.NewReader("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\n"))
+		testutil.Ok(t, err)
+		testutil.Assert(t, hasMeta)
+		testutil.Equals(t, "github.com/prometheus/prometheus/cmd/prometheus@v2.4.3+incompatible", pkg.String())
+
+		_, hasMeta, err = InspectReader("<stdin>", strings.NewReader("module _\n\ngo 1.14\n"))
+		testutil.NotOk(t, err)
+		testutil.Assert(t, !hasMeta)
+		testutil.Equals(t, "<stdin>: no direct package found; empty module?", err.Error())
+
+		_, _, err = InspectReader("<stdin>", strings.NewReader("require"))
+		testutil.NotOk(t, err)
+		testutil.Equals(t, "parse: <stdin>:1: usage: require module/path v1.2.3", err.Error())
+	})
+	t.Run("extra comments", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "test3.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus hint CGO_ENABLED=1 -tags=yolo -ldflags=-X=main.version=1
+`), os.ModePerm))
+
+		comments, err := ModDirectComments(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, []string{"cmd/prometheus", "hint", "CGO_ENABLED=1", "-tags=yolo", "-ldflags=-X=main.version=1"}, comments)
+
+		// Only the first plain element is the package suffix.
+		pkg, err := ModDirectPackage(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, Package{
+			Module:     module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+			RelPath:    "cmd/prometheus",
+			BuildEnvs:  []string{"CGO_ENABLED=1"},
+			BuildFlags: []string{"-tags=yolo", "-ldflags=-X=main.version=1"},
+		}, pkg)
+	})
+}
+
+func TestReproduce(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+	pkg, err := Reproduce(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, Package{
+		Module:     module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+		RelPath:    "cmd/prometheus",
+		BuildEnvs:  []string{"CGO_ENABLED=1"},
+		BuildFlags: []string{"-tags=yolo"},
+	}, pkg)
+	testutil.Equals(t, "github.com/prometheus/prometheus/cmd/prometheus@v2.4.3+incompatible", pkg.String())
+	expectContent(t, content, testFile)
+
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire (\n\tgithub.com/fatih/faillint v1.5.0\n\tgithub.com/fatih/faillint v1.6.0\n)\n"), os.ModePerm))
+	_, err = Reproduce(testFile)
+	testutil.NotOk(t, err)
+	testutil.Equals(t, testFile+": duplicated direct require of github.com/fatih/faillint module", err.Error())
+}
+
+func TestModDirectPackage_FullImportPathInRequire(t *testing.T) {
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus/cmd/prometheus v2.4.3+incompatible // CGO_ENABLED=1
+`
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+	expected := Package{Module: module.Version{Path: "github.com/prometheus/prometheus/cmd/prometheus", Version: "v2.4.3+incompatible"}, BuildEnvs: []string{"CGO_ENABLED=1"}}
+
+	// Without readable sum file module root can't be told apart, so the pin reads as it is.
+	for _, sum := range []string{"", "<<<<<<< HEAD\ngithub.com/prometheus/prometheus v2.4.3+incompatible h1:yolo=\n"} {
+		testutil.Ok(t, os.WriteFile(SumFilePath(testFile), []byte(sum), os.ModePerm))
+
+		pkg, err := ModDirectPackage(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, expected, pkg)
+
+		pkgs, err := ListPinnedMainPackages(log.New(io.Discard, "", 0), filepath.Dir(testFile), false)
+		testutil.Ok(t, err)
+		testutil.Equals(t, 1, len(pkgs))
+		testutil.Equals(t, "github.com/prometheus/prometheus/cmd/prometheus", pkgs[0].PackagePath)
+	}
+
+	testutil.Ok(t, os.WriteFile(SumFilePath(testFile), []byte("github.com/prometheus/prometheus v2.4.3+incompatible h1:yolo=\ngithub.com/prometheus/prometheus v2.4.3+incompatible/go.mod h1:yolo=\n"), os.ModePerm))
+	expectedErr := testFile + ": require path github.com/prometheus/prometheus/cmd/prometheus is a package path, not a module path; remove the module file and run bingo get github.com/prometheus/prometheus/cmd/prometheus@v2.4.3+incompatible again to fix the pin: this pinning scheme is not supported"
+	_, err := ModDirectPackage(testFile)
+	testutil.NotOk(t, err)
+	testutil.Assert(t, errors.Is(err, ErrPackagePathRequire), "expected ErrPackagePathRequire, got %v", err)
+	testutil.Equals(t, expectedErr, err.Error())
+
+	// Content alone is not enough to detect it.
+	pkg, _, err := InspectReader("test.mod", strings.NewReader(content))
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, pkg)
+
+	// Pin is reported and kept, even with remMalformed, as it can be fixed.
+	b := &bytes.Buffer{}
+	pkgs, err := ListPinnedMainPackages(log.New(b, "", 0), filepath.Dir(testFile), true)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(pkgs))
+	testutil.Equals(t, "found module file "+testFile+" with unsupported pin, skipping: "+expectedErr+"\n", b.String())
+	expectContent(t, content, testFile)
+}
+
+func TestModGoFlags(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	for _, tcase := range []struct {
+		name        string
+		comment     string
+		expected    []string
+		expectedErr string
+	}{
+		{name: "no comment"},
+		{name: "safe flags", comment: "// goflags: -buildvcs=false -trimpath -tags=netgo,osusergo", expected: []string{"-buildvcs=false", "-trimpath", "-tags=netgo,osusergo"}},
+		{name: "not allowed flag", comment: "// goflags: -trimpath -toolexec=/bin/sh", expectedErr: "test.mod: flag \"-toolexec=/bin/sh\" in goflags comment is not allowed; allowed flags: -buildvcs, -tags, -trimpath"},
+		// Builds always use -mod=mod after the pinned flags, so e.g. -mod=readonly would be silently overridden.
+		{name: "mod flag", comment: "// goflags: -mod=readonly", expectedErr: "test.mod: flag \"-mod=readonly\" in goflags comment is not allowed; allowed flags: -buildvcs, -tags, -trimpath"},
+		{name: "not allowed value", comment: "// goflags: -buildvcs=maybe", expectedErr: "test.mod: flag \"-buildvcs=maybe\" in goflags comment has not allowed value; allowed values: true, false, auto"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+` + tcase.comment + `
+go 1.14
+
+require github.com/fatih/faillint v1.5.0
+`
+			testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+			flags, err := ModGoFlags(testFile)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, strings.ReplaceAll(tcase.expectedErr, "test.mod", testFile), err.Error())
+
+				_, err = OpenModFile(testFile)
+				testutil.NotOk(t, err)
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, flags)
+
+			mf, err := OpenModFile(testFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, mf.GoFlags())
+			testutil.Ok(t, mf.Close())
+			expectContent(t, content, testFile)
+		})
+	}
+}
+
+func TestModAllRequires(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/alecthomas/kingpin v2.2.6+incompatible // indirect
+)
+`
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+	mods, err := ModAllRequires(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []module.Version{
+		{Path: "github.com/efficientgo/core", Version: "v1.0.0-rc.0"},
+		{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+		{Path: "github.com/alecthomas/kingpin", Version: "v2.2.6+incompatible"},
+	}, mods)
+	expectContent(t, content, testFile)
+}
+
+func TestVerifyMeta(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, tcase := range []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus -tags=yolo
+`,
+		},
+		{
+			name: "valid without package suffix",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // CGO_ENABLED=1
+`,
+		},
+		{
+			name: "no meta",
+			content: `module _
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`,
+			expectedErr: `test.mod: module line "module _" does not have bingo meta comment "Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT"`,
+		},
+		{
+			name: "package suffix outside of module",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // ../cmd/prometheus
+`,
+			expectedErr: `test.mod: require line "require github.com/prometheus/prometheus v2.4.3+incompatible // ../cmd/prometheus" has package suffix "../cmd/prometheus" that does not resolve to package within github.com/prometheus/prometheus module`,
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, "test.mod")
+			testutil.Ok(t, os.WriteFile(testFile, []byte(tcase.content), os.ModePerm))
+
+			err := VerifyMeta(testFile)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				// Not filepath.Join(tmpDir, tcase.expectedErr), as it would clean up paths within the message.
+				testutil.Equals(t, strings.Replace(tcase.expectedErr, "test.mod", testFile, 1), err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+		})
+	}
+}
+
+func TestOpenModFile_MetaCommentPlacement(t *testing.T) {
+	defer func(first bool) { MetaCommentFirst = first }(MetaCommentFirst)
+
+	original := `// Copyright header.
+
+module _ // Licensed under the Apache License 2.0.
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`
+	for _, tcase := range []struct {
+		first      bool
+		moduleLine string
+	}{
+		{first: false, moduleLine: "module _ // Licensed under the Apache License 2.0. // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT"},
+		{first: true, moduleLine: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT // Licensed under the Apache License 2.0."},
+	} {
+		t.Run(fmt.Sprintf("first=%v", tcase.first), func(t *testing.T) {
+			MetaCommentFirst = tcase.first
+
+			testFile := filepath.Join(t.TempDir(), "test.mod")
+			testutil.Ok(t, os.WriteFile(testFile, []byte(original), os.ModePerm))
+
+			expected := strings.Replace(original, "module _ // Licensed under the Apache License 2.0.", tcase.moduleLine, 1)
+			// Stable across reopen and reformat.
+			for i := 0; i < 3; i++ {
+				mf, err := OpenModFile(testFile)
+				testutil.Ok(t, err)
+				testutil.Ok(t, mf.SetGoVersion(mf.GoVersion()))
+				testutil.Ok(t, mf.Close())
+				expectContent(t, expected, testFile)
+			}
+
+			ok, err := ModHasMeta(testFile)
+			testutil.Ok(t, err)
+			testutil.Assert(t, ok)
+
+			testutil.Ok(t, RemoveMetaFromMod(testFile))
+			expectContent(t, strings.Replace(original, " // cmd/prometheus", "", 1), testFile)
+		})
+	}
+}
+
+func TestMetaComplete(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, tcase := range []struct {
+		file     string
+		content  string
+		expected bool
+		// repair is package suffix to repair the file with, if any.
+		repair string
+	}{
+		{file: "goimports.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire golang.org/x/tools v0.1.5 // cmd/goimports\n", expected: true},
+		{file: "faillint.1.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n", expected: true},
+		{file: "mdox.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/bwplotka/mdox/v2 v2.0.0 // CGO_ENABLED=0\n", expected: true},
+		// Package suffix stripped.
+		{file: "goimports.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire golang.org/x/tools v0.1.5 // CGO_ENABLED=0\n", repair: "cmd/goimports"},
+		// No meta comment.
+		{file: "faillint.mod", content: "module _\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"},
+	} {
+		t.Run(tcase.file, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, tcase.file)
+			testutil.Ok(t, os.WriteFile(testFile, []byte(tcase.content), os.ModePerm))
+
+			ok, err := MetaComplete(testFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, ok)
+			if tcase.repair == "" {
+				return
+			}
+
+			testutil.Ok(t, AddMetaToModFor(testFile, "golang.org/x/tools", tcase.repair))
+			ok, err = MetaComplete(testFile)
+			testutil.Ok(t, err)
+			testutil.Assert(t, ok)
+		})
+	}
+}
+
+func TestModName(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	testFile := filepath.Join(tmpDir, "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n"), os.ModePerm))
+	name, err := ModName(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "_", name)
+
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module github.com/yolo/edited\n\ngo 1.14\n"), os.ModePerm))
+	name, err = ModName(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "github.com/yolo/edited", name)
+
+	testutil.Ok(t, os.WriteFile(testFile, []byte("go 1.14\n"), os.ModePerm))
+	name, err = ModName(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "", name)
+
+	_, err = ModName(filepath.Join(tmpDir, "nope.mod"))
+	testutil.NotOk(t, err)
+}
+
+func TestModHasMeta(t *testing.T) {
+	defer func(c string) { MetaComment = c }(MetaComment)
+	MetaComment = "Auto generated by https://github.com/collinforsyth/bingo. DO NOT EDIT"
+
+	tmpDir := t.TempDir()
+
+	t.Run("file written with custom marker", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "custom.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte("module _ // some comment\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\n"), os.ModePerm))
+
+		mf, err := OpenModFile(testFile)
+		testutil.Ok(t, err)
+		testutil.Ok(t, mf.Close())
+		expectContent(t, `module _ // some comment // Auto generated by https://github.com/collinforsyth/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`, testFile)
+
+		ok, err := ModHasMeta(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, ok)
+		testutil.Ok(t, VerifyMeta(testFile))
+	})
+	t.Run("file written with legacy marker", func(t *testing.T) {
+		legacy := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`
+		testFile := filepath.Join(tmpDir, "legacy.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(legacy), os.ModePerm))
+
+		ok, err := ModHasMeta(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, ok)
+
+		// Legacy marker is kept, so files do not change on marker change.
+		mf, err := OpenModFile(testFile)
+		testutil.Ok(t, err)
+		testutil.Ok(t, mf.Close())
+		expectContent(t, legacy, testFile)
+	})
+	t.Run("file without marker", func(t *testing.T) {
+		testFile := filepath.Join(tmpDir, "no-meta.mod")
+		testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n"), os.ModePerm))
+
+		ok, err := ModHasMeta(testFile)
+		testutil.Ok(t, err)
+		testutil.Assert(t, !ok)
+	})
+}
+
+func TestRemoveMetaFromMod(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	original := `module _
+
+go 1.14
+
+// Some comment.
+
+replace github.com/prometheus/prometheus => github.com/bwplotka/prometheus v2.4.4+incompatible
+
+require github.com/prometheus/prometheus v2.4.3+incompatible
+`
+	testFile := filepath.Join(tmpDir, "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(original), os.ModePerm))
+
+	mf, err := OpenModFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, mf.SetDirectRequire(Package{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus", BuildEnvs: []string{"CGO_ENABLED=1"}}))
+	testutil.Ok(t, mf.Close())
+	expectContent(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+// Some comment.
+
+replace github.com/prometheus/prometheus => github.com/bwplotka/prometheus v2.4.4+incompatible
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1
+`, testFile)
+
+	testutil.Ok(t, RemoveMetaFromMod(testFile))
+	expectContent(t, original, testFile)
+	ok, err := ModHasMeta(testFile)
+	testutil.Ok(t, err)
+	testutil.Assert(t, !ok)
+
+	// Noop for file without meta.
+	testutil.Ok(t, RemoveMetaFromMod(testFile))
+	expectContent(t, original, testFile)
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, tcase := range []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:    "consistent",
+			content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\n",
+		},
+		{
+			name:        "edited module line",
+			content:     "module github.com/yolo/tools // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\n",
+			expectedErr: `test.mod: module line has path "github.com/yolo/tools", expected "_" as set by bingo`,
+		},
+		{
+			name:    "everything wrong",
+			content: "module yolo\n\ngo 1.14\n\nrequire prometheus v2.4.3+incompatible // ../cmd/prometheus\n",
+			expectedErr: `3 errors: test.mod: module line has path "yolo", expected "_" as set by bingo; ` +
+				`test.mod: module line "module yolo" does not have bingo meta comment "Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT"; ` +
+				`test.mod: "prometheus" is not a valid module path: missing dot in first path element; expected path like github.com/org/repo`,
+		},
+		{
+			name:        "package suffix outside of module",
+			content:     "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // ../cmd/prometheus\n",
+			expectedErr: `test.mod: require line "require github.com/prometheus/prometheus v2.4.3+incompatible // ../cmd/prometheus" has package suffix "../cmd/prometheus" that does not resolve to package within github.com/prometheus/prometheus module`,
+		},
+		{
+			name:        "no direct require",
+			content:     "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n",
+			expectedErr: "test.mod: no direct package found; empty module?",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, "test.mod")
+			testutil.Ok(t, os.WriteFile(testFile, []byte(tcase.content), os.ModePerm))
+
+			err := ConsistencyCheck(testFile)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, strings.NewReplacer("test.mod", testFile, "test.sum", SumFilePath(testFile)).Replace(tcase.expectedErr), err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+		})
+	}
+}
+
+func TestHasMisplacedIndirect(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, tcase := range []struct {
+		requires string
+		expected bool
+	}{
+		{requires: "require github.com/prometheus/prometheus v2.4.3+incompatible // indirect; cmd/prometheus", expected: true},
+		{requires: "require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus"},
+		{requires: "require (\n\tgithub.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect\n)"},
+		{requires: "require (\n\tgithub.com/prometheus/prometheus v2.4.3+incompatible // indirect\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect\n)"},
+		{requires: ""},
+	} {
+		t.Run(tcase.requires, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, "test.mod")
+			testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n\n"+tcase.requires+"\n"), os.ModePerm))
+
+			ok, err := HasMisplacedIndirect(testFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, ok)
+		})
+	}
+}
+
+func TestValidateModFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, tcase := range []struct {
+		name        string
+		content     string
+		sum         string
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`,
+		},
+		{
+			name: "duplicated and multiple direct requires",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/prometheus/prometheus v2.5.0+incompatible // cmd/prometheus
+	github.com/prometheus/alertmanager v0.21.0 // cmd/alertmanager
+)
+`,
+			expectedErr: "2 errors: test.mod: duplicated direct require of github.com/prometheus/prometheus module; " +
+				"test.mod: expected one direct require, found 2 different ones",
+		},
+		{
+			name: "only require marked as indirect",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // indirect
+`,
+			expectedErr: "test.mod: the only require of github.com/prometheus/prometheus module is marked as indirect; run bingo get again to fix the pin",
+		},
+		{
+			name: "placeholder version",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v0.0.0 // cmd/prometheus
+	github.com/efficientgo/core v0.0.0 // indirect
+)
+`,
+			expectedErr: "test.mod: direct require of github.com/prometheus/prometheus module has placeholder version v0.0.0, most likely left by failed version resolution; run bingo get github.com/prometheus/prometheus/cmd/prometheus again to fix the pin",
+		},
+		{
+			name: "pseudo version",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/efficientgo/tools/copyright v0.0.0-20210201224146-3d78f4d30648
+`,
+		},
+		{
+			name: "package path in require",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus/cmd/prometheus v2.4.3+incompatible
+`,
+			sum:         "github.com/prometheus/prometheus v2.4.3+incompatible h1:yolo=\ngithub.com/prometheus/prometheus v2.4.3+incompatible/go.mod h1:yolo=\n",
+			expectedErr: "test.mod: require path github.com/prometheus/prometheus/cmd/prometheus is a package path, not a module path; remove the module file and run bingo get github.com/prometheus/prometheus/cmd/prometheus@v2.4.3+incompatible again to fix the pin: this pinning scheme is not supported",
+		},
+		{
+			name: "malformed sum file",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible
+`,
+			sum:         "<<<<<<< HEAD\ngithub.com/prometheus/prometheus v2.4.3+incompatible h1:yolo=\n",
+			expectedErr: "test.mod: sum file: test.sum:1: malformed go.sum line, expected '<module> <version> <hash>', got \"<<<<<<< HEAD\"",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			testFile := filepath.Join(tmpDir, "test.mod")
+			testutil.Ok(t, os.WriteFile(testFile, []byte(tcase.content), os.ModePerm))
+			testutil.Ok(t, os.WriteFile(SumFilePath(testFile), []byte(tcase.sum), os.ModePerm))
+
+			err := ValidateModFile(testFile)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, strings.NewReplacer("test.mod", testFile, "test.sum", SumFilePath(testFile)).Replace(tcase.expectedErr), err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+		})
+	}
+}
+
+func TestDetectNameCollisions(t *testing.T) {
+	var (
+		serverA  = Package{Module: module.Version{Path: "github.com/bwplotka/server", Version: "v1.2.0"}}
+		serverA2 = Package{Module: module.Version{Path: "github.com/bwplotka/server", Version: "v1.3.0"}}
+		serverB  = Package{Module: module.Version{Path: "github.com/efficientgo/tools", Version: "v0.1.0"}, RelPath: "cmd/Server"}
+		serverC  = Package{Module: module.Version{Path: "github.com/yolo/server/v2", Version: "v2.0.0"}}
+		faillint = Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}
+	)
+
+	testutil.Equals(t, map[string][]Package{}, DetectNameCollisions([]Package{serverA, serverA2, faillint}))
+	testutil.Equals(t, map[string][]Package{
+		"server": {serverA, serverB, serverC},
+	}, DetectNameCollisions([]Package{serverA, faillint, serverB, serverA2, serverC}))
+}
+
+func TestVariableName(t *testing.T) {
+	for _, tcase := range []struct {
+		name     string
+		expected string
+	}{
+		{name: "goimports", expected: "GOIMPORTS"},
+		{name: "golangci-lint", expected: "GOLANGCI_LINT"},
+		{name: "protoc-gen-go.v2", expected: "PROTOC_GEN_GO_V2"},
+		{name: "Faillint2", expected: "FAILLINT2"},
+		{name: "my_tool", expected: "MY_TOOL"},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			testutil.Equals(t, tcase.expected, VariableName(tcase.name))
+		})
+	}
+}
+
+func TestListPinnedMainPackages_VariableNameCollision(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, f := range []string{"foo-bar.mod", "foo_bar.mod", "other.mod"} {
+		testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, f), []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/bwplotka/tool v1.0.0
+`), os.ModePerm))
+	}
+
+	pkgs, err := ListPinnedMainPackages(log.New(os.Stderr, "", 0), tmpDir, false)
+	testutil.Ok(t, err)
+
+	var names []string
+	for _, p := range pkgs {
+		names = append(names, p.EnvVarName)
+	}
+	testutil.Equals(t, []string{"FOO_BAR_36087877", "FOO_BAR_01EFFFDD", "OTHER"}, names)
+}
+
+func TestValidateModulePath(t *testing.T) {
+	for _, p := range []string{"github.com/bwplotka/bingo", "github.com/bwplotka/bingo/v2", "gopkg.in/yaml.v2", "sigs.k8s.io/kustomize/kustomize/v3"} {
+		testutil.Ok(t, ValidateModulePath(p))
+		testutil.Ok(t, ValidatePackagePath(p))
+	}
+	for _, p := range []string{"gopkg.in/yaml.v2/cmd/yaml", "github.com/Org/Repo/cmd/v1"} {
+		testutil.NotOk(t, ValidateModulePath(p))
+		testutil.Ok(t, ValidatePackagePath(p))
+	}
+
+	err := ValidateModulePath("not-a-real/path")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, `"not-a-real/path" is not a valid module path: missing dot in first path element; expected path like github.com/org/repo`, err.Error())
+
+	err = ValidatePackagePath("not-a-real/path/cmd/tool")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, `"not-a-real/path/cmd/tool" is not a valid package path: missing dot in first path element; expected path like github.com/org/repo/cmd/tool`, err.Error())
+
+	err = ValidatePackagePath("github.com/org/repo/cmd tool")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, `"github.com/org/repo/cmd tool" is not a valid package path: invalid char ' '; expected path like github.com/org/repo/cmd/tool`, err.Error())
+
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"), os.ModePerm))
+	testutil.NotOk(t, RenameModulePath(testFile, "github.com/fatih/faillint", "faillint"))
+	testutil.NotOk(t, AddMetaToModFor(testFile, "faillint", ""))
+	testutil.NotOk(t, SetVersion(testFile, "faillint", "v1.6.0"))
+}
+
+func TestSumFilePath(t *testing.T) {
+	testutil.Equals(t, filepath.Join(".bingo", "faillint.sum"), SumFilePath(filepath.Join(".bingo", "faillint.mod")))
+	testutil.Equals(t, filepath.Join(".bingo", "go.sum"), SumFilePath(filepath.Join(".bingo", FakeRootModFileName)))
+	testutil.Equals(t, "faillint.v1.2.3.sum", SumFilePath("faillint.v1.2.3.mod"))
+
+	dir := t.TempDir()
+	modFile := filepath.Join(dir, "faillint.mod")
+	testutil.Ok(t, os.WriteFile(filepath.Join(dir, "faillint.sum"), []byte("github.com/fatih/faillint v1.5.0 h1:yolo=\ngithub.com/fatih/faillint v1.5.0/go.mod h1:yolo2=\n"), os.ModePerm))
+	entries, err := ModSumEntries(modFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, map[string][]string{
+		"github.com/fatih/faillint@v1.5.0":        {"h1:yolo="},
+		"github.com/fatih/faillint@v1.5.0/go.mod": {"h1:yolo2="},
+	}, entries)
+}
+
+func TestVerifySumCoverage(t *testing.T) {
+	dir := t.TempDir()
+	modFile := filepath.Join(dir, "goimports.mod")
+	writePin(t, dir, "goimports.mod", "golang.org/x/tools v0.1.5 // cmd/goimports")
+
+	err := VerifySumCoverage(modFile)
+	testutil.NotOk(t, err)
+	testutil.Equals(t, modFile+": missing checksum for golang.org/x/tools/cmd/goimports@v0.1.5 in "+SumFilePath(modFile)+"; run bingo get golang.org/x/tools/cmd/goimports@v0.1.5 again to fix the pin", err.Error())
+
+	testutil.Ok(t, os.WriteFile(SumFilePath(modFile), []byte("golang.org/x/tools v0.1.5/go.mod h1:yolo2=\n"), os.ModePerm))
+	testutil.NotOk(t, VerifySumCoverage(modFile))
+
+	testutil.Ok(t, os.WriteFile(SumFilePath(modFile), []byte("golang.org/x/tools v0.1.4 h1:yolo=\ngolang.org/x/tools v0.1.5/go.mod h1:yolo2=\n"), os.ModePerm))
+	testutil.NotOk(t, VerifySumCoverage(modFile))
+
+	testutil.Ok(t, os.WriteFile(SumFilePath(modFile), []byte("golang.org/x/tools v0.1.5 h1:yolo=\ngolang.org/x/tools v0.1.5/go.mod h1:yolo2=\n"), os.ModePerm))
+	testutil.Ok(t, VerifySumCoverage(modFile))
+
+	empty := filepath.Join(dir, "empty.mod")
+	testutil.Ok(t, os.WriteFile(empty, []byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n"), os.ModePerm))
+	testutil.Assert(t, errors.Is(VerifySumCoverage(empty), ErrNoDirectPackage))
+}
+
+func TestOutdated(t *testing.T) {
+	dir := t.TempDir()
+	writePin(t, dir, "faillint.mod", "github.com/fatih/faillint v1.5.0")
+	writePin(t, dir, "faillint.1.mod", "github.com/fatih/faillint v1.4.0")
+	writePin(t, dir, "goimports.mod", "golang.org/x/tools v0.1.5 // cmd/goimports")
+	testutil.Ok(t, os.WriteFile(filepath.Join(dir, FakeRootModFileName), []byte("module _\n"), os.ModePerm))
+
+	var resolved []string
+	pins, err := Outdated(dir, func(modulePath string) (string, error) {
+		resolved = append(resolved, modulePath)
+		return map[string]string{"github.com/fatih/faillint": "v1.5.0", "golang.org/x/tools": "v0.1.6"}[modulePath], nil
+	})
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{"github.com/fatih/faillint", "golang.org/x/tools"}, resolved)
+	testutil.Equals(t, []OutdatedPin{
+		{ModFile: filepath.Join(dir, "faillint.1.mod"), Package: Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.4.0"}}, Latest: "v1.5.0", Behind: true},
+		{ModFile: filepath.Join(dir, "faillint.mod"), Package: Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, Latest: "v1.5.0"},
+		{ModFile: filepath.Join(dir, "goimports.mod"), Package: Package{Module: module.Version{Path: "golang.org/x/tools", Version: "v0.1.5"}, RelPath: "cmd/goimports"}, Latest: "v0.1.6", Behind: true},
+	}, pins)
+
+	_, err = Outdated(dir, func(string) (string, error) { return "", errors.New("no network") })
+	testutil.NotOk(t, err)
+	testutil.Equals(t, filepath.Join(dir, "faillint.1.mod")+": latest version of github.com/fatih/faillint: no network", err.Error())
+}
+
+func TestExpandReplacePaths(t *testing.T) {
+	const content = `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+replace github.com/fatih/faillint => ${WORKSPACE}/faillint // local checkout
+
+replace (
+	github.com/efficientgo/core => $HOME/core
+	github.com/miekg/dns => github.com/miekg/dns v1.0.4
+	k8s.io/klog => ../klog
+)
+
+require github.com/fatih/faillint v1.5.0
+`
+	modFile := filepath.Join(t.TempDir(), "faillint.mod")
+	testutil.Ok(t, os.WriteFile(modFile, []byte(content), os.ModePerm))
+
+	env := map[string]string{"WORKSPACE": "/src", "HOME": "/home/yolo"}
+	b, err := ExpandReplacePaths(modFile, func(name string) string { return env[name] })
+	testutil.Ok(t, err)
+	testutil.Equals(t, `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+replace github.com/fatih/faillint => /src/faillint // local checkout
+
+replace (
+	github.com/efficientgo/core => /home/yolo/core
+	github.com/miekg/dns => github.com/miekg/dns v1.0.4
+	k8s.io/klog => ../klog
+)
+
+require github.com/fatih/faillint v1.5.0
+`, string(b))
+	expectContent(t, content, modFile)
+
+	_, err = ExpandReplacePaths(modFile, func(name string) string { return map[string]string{"HOME": "/home/yolo"}[name] })
+	testutil.NotOk(t, err)
+	testutil.Equals(t, modFile+":5: replace path ${WORKSPACE}/faillint references empty variables [WORKSPACE]", err.Error())
+
+	_, err = ExpandReplacePaths(modFile, func(name string) string {
+		return map[string]string{"WORKSPACE": "github.com", "HOME": "/home/yolo"}[name]
+	})
+	testutil.NotOk(t, err)
+	testutil.Equals(t, modFile+":5: replace path ${WORKSPACE}/faillint expanded to github.com/faillint, which is not a local directory path", err.Error())
+}
+
+func TestMarshalPinsJSON(t *testing.T) {
+	b := &bytes.Buffer{}
+	testutil.Ok(t, MarshalPinsJSON([]Package{
+		{Module: module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"}, RelPath: "cmd/prometheus"},
+		{Module: module.Version{Path: "github.com/bwplotka/bingo", Version: "v0.0.0-20210220032951-036812b2e83c"}},
+	}, b))
+	testutil.Equals(t, `[
+  {
+    "module": "github.com/bwplotka/bingo",
+    "subpackage": "",
+    "importpath": "github.com/bwplotka/bingo",
+    "version": "v0.0.0-20210220032951-036812b2e83c",
+    "pseudoversion": true
+  },
+  {
+    "module": "github.com/prometheus/prometheus",
+    "subpackage": "cmd/prometheus",
+    "importpath": "github.com/prometheus/prometheus/cmd/prometheus",
+    "version": "v2.4.3+incompatible",
+    "pseudoversion": false
+  }
+]
+`, b.String())
+
+	b.Reset()
+	testutil.Ok(t, MarshalPinsJSON(nil, b))
+	testutil.Equals(t, "[]\n", b.String())
+}
+
+func TestListManagedMods(t *testing.T) {
+	tmpDir := t.TempDir()
+	for f, content := range map[string]string{
+		"b.mod":         "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n",
+		"a.mod":         "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n",
+		"a.1.mod":       "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n",
+		"user.mod":      "module github.com/bwplotka/user\n\ngo 1.14\n",
+		"go.mod":        "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n",
+		"variables.env": "A=1\n",
+	} {
+		testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, f), []byte(content), os.ModePerm))
+	}
+
+	mods, err := ListManagedMods(tmpDir)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []string{filepath.Join(tmpDir, "a.1.mod"), filepath.Join(tmpDir, "a.mod"), filepath.Join(tmpDir, "b.mod")}, mods)
+}
+
+func TestIsManagedToolMod(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, tcase := range []struct {
+		file    string
+		content string
+		shared  bool
+		managed bool
+	}{
+		{file: "faillint.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n", managed: true},
+		{file: "prometheus.1.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire (\n\tgithub.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\n\tgithub.com/efficientgo/core v1.0.0-rc.0 // indirect\n)\n", managed: true},
+		{file: "go.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n", shared: true},
+		{file: "empty.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n"},
+		{file: "user.mod", content: "module github.com/bwplotka/user\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"},
+		{file: "many.mod", content: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire (\n\tgithub.com/fatih/faillint v1.5.0\n\tgithub.com/efficientgo/core v1.0.0-rc.0\n)\n"},
+	} {
+		t.Run(tcase.file, func(t *testing.T) {
+			f := filepath.Join(tmpDir, tcase.file)
+			testutil.Ok(t, os.WriteFile(f, []byte(tcase.content), os.ModePerm))
+
+			testutil.Equals(t, tcase.shared, IsSharedMod(f))
+			managed, err := IsManagedToolMod(f)
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.managed, managed)
+		})
+	}
+}
+
+func TestInspectDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	for f, content := range map[string]string{
+		"faillint.mod": "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n",
+		"broken.mod":   "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\nrequire (\n",
+		"empty.mod":    "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n",
+		"many.mod":     "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire (\n\tgithub.com/fatih/faillint v1.5.0\n\tgithub.com/efficientgo/core v1.0.0-rc.0\n)\n",
+		"user.mod":     "module github.com/bwplotka/user\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible\n",
+		"go.mod":       "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n",
+	} {
+		testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, f), []byte(content), os.ModePerm))
+	}
+
+	results, err := InspectDir(tmpDir)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 5, len(results))
+
+	for i, name := range []string{"broken.mod", "empty.mod", "faillint.mod", "many.mod", "user.mod"} {
+		testutil.Equals(t, filepath.Join(tmpDir, name), results[i].ModFile)
+	}
+	testutil.NotOk(t, results[0].Err)
+	testutil.Assert(t, errors.Is(results[1].Err, ErrNoDirectPackage))
+	testutil.Assert(t, results[1].HasMeta)
+
+	testutil.Ok(t, results[2].Err)
+	testutil.Assert(t, results[2].HasMeta)
+	testutil.Assert(t, !results[2].Incompatible)
+	testutil.Equals(t, "github.com/fatih/faillint@v1.5.0", results[2].Package.String())
+
+	testutil.NotOk(t, results[3].Err)
+	testutil.Equals(t, filepath.Join(tmpDir, "many.mod")+": expected one direct require, found 2 different ones", results[3].Err.Error())
+	testutil.Equals(t, "github.com/fatih/faillint@v1.5.0", results[3].Package.String())
+
+	testutil.Ok(t, results[4].Err)
+	testutil.Assert(t, !results[4].HasMeta)
+	testutil.Assert(t, results[4].Incompatible)
+}
+
+func TestValidateNoPseudo(t *testing.T) {
+	dir := t.TempDir()
+	writePin(t, dir, "faillint.mod", "github.com/fatih/faillint v1.5.0")
+	writePin(t, dir, "copyright.mod", "github.com/efficientgo/tools/copyright v0.0.0-20210201224146-3d78f4d30648")
+	writePin(t, dir, "mdox.mod", "github.com/bwplotka/mdox v0.9.1-0.20220621102515-1691a3e4dc4a")
+	writePin(t, dir, "prometheus.mod", "github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus")
+
+	pseudo, err := ValidateNoPseudo(dir)
+	testutil.Ok(t, err)
+	testutil.Equals(t, []Package{
+		{Module: module.Version{Path: "github.com/efficientgo/tools/copyright", Version: "v0.0.0-20210201224146-3d78f4d30648"}},
+		{Module: module.Version{Path: "github.com/bwplotka/mdox", Version: "v0.9.1-0.20220621102515-1691a3e4dc4a"}},
+	}, pseudo)
+
+	pseudo, err = ValidateNoPseudo(t.TempDir())
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(pseudo))
+}
+
+func TestPinsDigest(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	for _, dir := range []string{dirA, dirB} {
+		writePin(t, dir, "faillint.mod", "github.com/fatih/faillint v1.5.0")
+		writePin(t, dir, "goimports.mod", "golang.org/x/tools v0.1.5 // cmd/goimports")
+	}
+	// Not pins.
+	testutil.Ok(t, os.WriteFile(filepath.Join(dirB, FakeRootModFileName), []byte("module _\n"), os.ModePerm))
+	testutil.Ok(t, os.WriteFile(filepath.Join(dirB, "Variables.mk"), []byte("GO ?= $(shell which go)\n"), os.ModePerm))
+
+	a, err := PinsDigest(dirA)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 64, len(a))
+	b, err := PinsDigest(dirB)
+	testutil.Ok(t, err)
+	testutil.Equals(t, a, b)
+
+	for _, change := range []func(){
+		func() { writePin(t, dirB, "goimports.mod", "golang.org/x/tools v0.1.6 // cmd/goimports") },
+		func() { writePin(t, dirB, "goimports.mod", "golang.org/x/tools v0.1.6 // cmd/goimports -tags=yolo") },
+		func() { writePin(t, dirB, "faillint.1.mod", "github.com/fatih/faillint v1.4.0") },
+	} {
+		change()
+		c, err := PinsDigest(dirB)
+		testutil.Ok(t, err)
+		testutil.Assert(t, c != b, "expected digest to change")
+		b = c
+	}
+}
+
+func TestListPinnedMainPackages_Retracted(t *testing.T) {
+	tmpDir := t.TempDir()
+	testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, "tool.mod"), []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.16
+
+require github.com/bwplotka/tool v1.0.1 // cmd/tool
+
+retract [v1.0.0, v1.0.2] // Broken build.
+`), os.ModePerm))
+	testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, "other.mod"), []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.16
+
+require github.com/bwplotka/other v1.0.1
+`), os.ModePerm))
+
+	b := &bytes.Buffer{}
+	pkgs, err := ListPinnedMainPackages(log.New(b, "", 0), tmpDir, false)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(pkgs))
+	testutil.Equals(t, "WARNING: github.com/bwplotka/tool/cmd/tool@v1.0.1 pinned in tool.mod is retracted by its authors (Broken build.). Consider pinning other version.\n", b.String())
+
+	retracts, err := ModRetractDirectives(filepath.Join(tmpDir, "other.mod"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(retracts))
+}
+
+func TestListPinnedMainPackages_InvalidGoFlags(t *testing.T) {
+	tmpDir := t.TempDir()
+	bad := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+// goflags: -mod=readonly
+go 1.14
+
+require github.com/bwplotka/tool v1.0.0
+`
+	testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, "tool.mod"), []byte(bad), os.ModePerm))
+	testutil.Ok(t, os.WriteFile(filepath.Join(tmpDir, "other.mod"), []byte(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+// goflags: -trimpath
+go 1.14
+
+require github.com/bwplotka/other v1.0.0
+`), os.ModePerm))
+
+	b := &bytes.Buffer{}
+	pkgs, err := ListPinnedMainPackages(log.New(b, "", 0), tmpDir, true)
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(pkgs))
+	testutil.Equals(t, "github.com/bwplotka/other", pkgs[0].PackagePath)
+	testutil.Equals(t, []string{"-trimpath"}, pkgs[0].BuildFlags)
+	testutil.Equals(t, "found module file "+filepath.Join(tmpDir, "tool.mod")+" with invalid goflags comment, skipping: "+filepath.Join(tmpDir, "tool.mod")+": flag \"-mod=readonly\" in goflags comment is not allowed; allowed flags: -buildvcs, -tags, -trimpath\n", b.String())
+
+	// Pin is skipped, not removed, even with remMalformed.
+	expectContent(t, bad, filepath.Join(tmpDir, "tool.mod"))
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	for _, tcase := range []struct {
+		name        string
+		content     string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name: "legacy pin",
+			content: `module _
+
+go 1.14
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+)
+`,
+			expected: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1
+`,
+		},
+		{
+			name:     "no module line",
+			content:  "go 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n",
+			expected: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n",
+		},
+		{
+			name:     "no module line, header comment",
+			content:  "// Tools.\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n",
+			expected: "// Tools.\n\nmodule _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n",
+		},
+		{
+			name:     "current format",
+			content:  "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire   github.com/fatih/faillint   v1.5.0\n",
+			expected: "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire   github.com/fatih/faillint   v1.5.0\n",
+		},
+		{
+			name:        "user module",
+			content:     "module github.com/bwplotka/user\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n",
+			expectedErr: "test.mod: unrecognized pin format; module path \"github.com/bwplotka/user\" found, expected \"_\" or none",
+		},
+		{
+			name:        "many direct requires",
+			content:     "module _\n\ngo 1.14\n\nrequire (\n\tgithub.com/fatih/faillint v1.5.0\n\tgithub.com/efficientgo/core v1.0.0-rc.0\n)\n",
+			expectedErr: "test.mod: unrecognized pin format; expected exactly one direct require, found 2",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			b, err := MigrateLegacy("test.mod", strings.NewReader(tcase.content))
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, string(b))
+		})
+	}
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	for _, tcase := range []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:    "plain",
+			content: "module _\n\ngo 1.14\n\nrequire github.com/prometheus/prometheus v2.4.3+incompatible\n",
+		},
+		{
+			name: "with meta, indirect requires and directives",
+			content: `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.14
+
+// bingo:no_directive_fetch
+
+require (
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus CGO_ENABLED=1 -tags=yolo
+)
+
+replace github.com/miekg/dns => github.com/miekg/dns v1.0.4
+`,
+		},
+		{
+			name:        "no direct require",
+			content:     "module _\n\ngo 1.14\n",
+			expectedErr: "test.mod: no direct package found; empty module?",
+		},
+		{
+			name:        "malformed",
+			content:     "module _\n\ngo 1.14 yolo\n",
+			expectedErr: "parse: test.mod:3: go directive expects exactly one argument",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			err := CheckRoundTrip("test.mod", strings.NewReader(tcase.content))
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+		})
+	}
+}
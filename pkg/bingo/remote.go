@@ -0,0 +1,44 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/efficientgo/core/errcapture"
+	"github.com/efficientgo/core/errors"
+)
+
+// maxRemoteModFileSize limits size of module files fetched by InspectURL. Real pins are far smaller.
+const maxRemoteModFileSize = 1 << 20
+
+// InspectURL is like InspectReader, but fetches module file content with GET request to the given URL, e.g. to reuse
+// tool pins shared by other project. Use ctx to cancel or time out the request. Non 200 responses and content larger
+// than 1 MiB are rejected. The URL is used as the name in errors.
+func InspectURL(ctx context.Context, url string) (_ Package, hasMeta bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Package{}, false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Package{}, false, err
+	}
+	defer errcapture.Do(&err, resp.Body.Close, "close")
+
+	if resp.StatusCode != http.StatusOK {
+		return Package{}, false, errors.Newf("get %s: unexpected status %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteModFileSize+1))
+	if err != nil {
+		return Package{}, false, errors.Wrapf(err, "read %s", url)
+	}
+	if len(b) > maxRemoteModFileSize {
+		return Package{}, false, errors.Newf("%s: module file is larger than %d bytes", url, maxRemoteModFileSize)
+	}
+	return InspectReader(url, bytes.NewReader(b))
+}
@@ -0,0 +1,50 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/efficientgo/core/testutil"
+	"golang.org/x/mod/module"
+)
+
+func TestInspectURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/faillint.mod":
+			_, _ = w.Write([]byte("module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.14\n\nrequire github.com/fatih/faillint v1.5.0\n"))
+		case "/large.mod":
+			_, _ = w.Write([]byte("module _\n\n" + strings.Repeat("// yolo\n", maxRemoteModFileSize/8+1)))
+		case "/slow.mod":
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	pkg, hasMeta, err := InspectURL(context.Background(), srv.URL+"/faillint.mod")
+	testutil.Ok(t, err)
+	testutil.Assert(t, hasMeta)
+	testutil.Equals(t, Package{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.5.0"}}, pkg)
+
+	_, _, err = InspectURL(context.Background(), srv.URL+"/missing.mod")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, "get "+srv.URL+"/missing.mod: unexpected status 404 Not Found", err.Error())
+
+	_, _, err = InspectURL(context.Background(), srv.URL+"/large.mod")
+	testutil.NotOk(t, err)
+	testutil.Equals(t, srv.URL+"/large.mod: module file is larger than 1048576 bytes", err.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = InspectURL(ctx, srv.URL+"/slow.mod")
+	testutil.NotOk(t, err)
+	testutil.Assert(t, strings.Contains(err.Error(), context.Canceled.Error()), err.Error())
+}
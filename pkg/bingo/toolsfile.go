@@ -0,0 +1,65 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"strconv"
+
+	"github.com/efficientgo/core/errors"
+)
+
+// ImportsFromToolsFile returns package paths of blank imports from the given tools.go file, in the file order. Such
+// file is a common way of pinning tools in the main module: Go file with "tools" build constraint (e.g.
+// "//go:build tools") importing tool packages, so they are tracked in go.mod. Returned paths can be used to create
+// bingo pins, e.g. with bingo get. Error is returned if file has no "tools" build constraint.
+func ImportsFromToolsFile(r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read")
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "tools.go", b, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse")
+	}
+
+	var hasToolsTag bool
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package {
+			break
+		}
+		for _, c := range g.List {
+			if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				return nil, errors.Wrapf(err, "parse build constraint %q", c.Text)
+			}
+			if expr.Eval(func(tag string) bool { return tag == "tools" }) {
+				hasToolsTag = true
+			}
+		}
+	}
+	if !hasToolsTag {
+		return nil, errors.New("not a tools file, expected \"tools\" build constraint, e.g. //go:build tools")
+	}
+
+	var pkgs []string
+	for _, imp := range f.Imports {
+		if imp.Name == nil || imp.Name.Name != "_" {
+			continue
+		}
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unquote import %s", imp.Path.Value)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/efficientgo/core/testutil"
+)
+
+func TestImportsFromToolsFile(t *testing.T) {
+	for _, tcase := range []struct {
+		name        string
+		content     string
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name: "grouped imports",
+			content: `//go:build tools
+// +build tools
+
+// Package tools tracks tool dependencies.
+package tools
+
+import (
+	_ "github.com/fatih/faillint"
+	_ "golang.org/x/tools/cmd/goimports" // Formatting.
+
+	"fmt"
+)
+`,
+			expected: []string{"github.com/fatih/faillint", "golang.org/x/tools/cmd/goimports"},
+		},
+		{
+			name: "single import, legacy constraint",
+			content: `// +build tools
+
+package tools
+
+import _ "github.com/prometheus/prometheus/cmd/promtool"
+`,
+			expected: []string{"github.com/prometheus/prometheus/cmd/promtool"},
+		},
+		{
+			name:        "no tools constraint",
+			content:     "//go:build !tools\n\npackage tools\n\nimport _ \"github.com/fatih/faillint\"\n",
+			expectedErr: "not a tools file, expected \"tools\" build constraint, e.g. //go:build tools",
+		},
+		{
+			name:        "constraint after package clause",
+			content:     "package tools\n\n//go:build tools\n\nimport _ \"github.com/fatih/faillint\"\n",
+			expectedErr: "not a tools file, expected \"tools\" build constraint, e.g. //go:build tools",
+		},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			pkgs, err := ImportsFromToolsFile(strings.NewReader(tcase.content))
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, pkgs)
+		})
+	}
+}
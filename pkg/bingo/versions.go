@@ -0,0 +1,177 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bwplotka/bingo/pkg/mod"
+	"github.com/efficientgo/core/errors"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	semverLikeRegexp = regexp.MustCompile(`^v[0-9]`)
+	revisionRegexp   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/-]*$`)
+)
+
+// NormalizeVersion validates version (or version query) requested by user and returns it in canonical form.
+// Accepted are semantic versions (also with +incompatible suffix), pseudo-versions, version prefixes like "v1.2",
+// "latest", "upgrade", "patch", comparisons like "<v1.2.3" or ">=v1.2" and revisions (e.g. commit SHA or branch name)
+// which are resolved later by go. Versions that look like semantic versions (e.g. "v1...") have to be valid ones.
+func NormalizeVersion(version string) (string, error) {
+	switch {
+	case version == "":
+		return "", errors.New("empty version")
+	case version == "latest", version == "upgrade", version == "patch":
+		return version, nil
+	case strings.HasPrefix(version, "<"), strings.HasPrefix(version, ">"):
+		// Version comparison query, passed to go as is.
+		v := strings.TrimPrefix(version[1:], "=")
+		if !semver.IsValid(v) {
+			return "", errors.Newf("malformed version query %q; expected comparison with semantic version, e.g. '>=v1.2.3'", version)
+		}
+		return version, nil
+	case semverLikeRegexp.MatchString(version):
+		if !semver.IsValid(version) {
+			return "", errors.Newf("malformed version %q; not a valid semantic version", version)
+		}
+		if b := semver.Build(version); b != "" && b != "+incompatible" {
+			// Go modules do not use any other build metadata.
+			return strings.TrimSuffix(version, b), nil
+		}
+		return version, nil
+	case revisionRegexp.MatchString(version):
+		return version, nil
+	}
+	return "", errors.Newf("malformed version %q; expected semantic version, pseudo-version, 'latest' or revision", version)
+}
+
+// CompareVersions returns -1, 0 or 1 if version a is lower, equal or higher than version b. Both have to be valid
+// semantic versions; pseudo-versions compare by their base version and commit time, as go does. The +incompatible
+// suffix is ignored.
+func CompareVersions(a, b string) (int, error) {
+	for _, v := range []string{a, b} {
+		if !semver.IsValid(v) {
+			return 0, errors.Newf("can't compare version %q; not a valid semantic version", v)
+		}
+	}
+	return semver.Compare(a, b), nil
+}
+
+// IsRetracted returns true and rationale if given version is within any of the given retract directives.
+func IsRetracted(version string, retracts []mod.RetractDirective) (rationale string, ok bool) {
+	if !semver.IsValid(version) {
+		return "", false
+	}
+	for _, r := range retracts {
+		if semver.Compare(version, r.Low) >= 0 && semver.Compare(version, r.High) <= 0 {
+			return r.Rationale, true
+		}
+	}
+	return "", false
+}
+
+// IsPseudoVersion returns true if given version is a pseudo-version, so it points to a commit (e.g. of a branch like
+// "main") and not to a tag. All three forms are recognized: "vX.0.0-yyyymmddhhmmss-abcdefabcdef",
+// "vX.Y.Z-pre.0.yyyymmddhhmmss-abcdefabcdef" and "vX.Y.(Z+1)-0.yyyymmddhhmmss-abcdefabcdef".
+func IsPseudoVersion(version string) bool {
+	return module.IsPseudoVersion(version)
+}
+
+// IsIncompatible returns true if given version has "+incompatible" suffix, e.g. "v2.4.3+incompatible". Go uses such
+// versions for v2+ tags of modules that have no go.mod file at that tag or have no major version suffix in the module
+// path. Such module likely predates Go modules and newer version with proper module path might be available.
+func IsIncompatible(version string) bool {
+	return semver.IsValid(version) && semver.Build(version) == "+incompatible"
+}
+
+// ResolveLatest returns the highest of the given available versions (e.g. from "go list -m -versions") within the
+// major version of the current one, so upgrades never cross a major version boundary. Releases are preferred over
+// pre-releases, as go does for "latest". Current version is returned if nothing newer exists within its major version.
+func ResolveLatest(modulePath, currentVersion string, availableTags []string) (string, error) {
+	if !semver.IsValid(currentVersion) {
+		return "", errors.Newf("can't resolve latest version for %s; current version %q is not a valid semantic version", modulePath, currentVersion)
+	}
+	if _, pathMajor, ok := module.SplitPathVersion(modulePath); ok && pathMajor != "" {
+		if err := module.CheckPathMajor(currentVersion, pathMajor); err != nil {
+			return "", errors.Wrapf(err, "can't resolve latest version for %s", modulePath)
+		}
+	}
+
+	major := semver.Major(currentVersion)
+	var latest, latestPre string
+	for _, v := range availableTags {
+		if !semver.IsValid(v) || semver.Major(v) != major || module.IsPseudoVersion(v) {
+			continue
+		}
+		if semver.Prerelease(v) != "" {
+			if latestPre == "" || semver.Compare(v, latestPre) > 0 {
+				latestPre = v
+			}
+			continue
+		}
+		if latest == "" || semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	if latest == "" {
+		latest = latestPre
+	}
+	if latest == "" || semver.Compare(latest, currentVersion) <= 0 {
+		return currentVersion, nil
+	}
+	return latest, nil
+}
+
+// BumpVersion returns the next version after the given one, bumping its "patch", "minor" or "major" component, e.g.
+// "v1.3.0" for "v1.2.3" and "minor". Pre-release and build metadata are dropped, except +incompatible suffix. A
+// pre-release is followed by its release, so if the lower parts are zero, only the pre-release is dropped, e.g.
+// "v1.3.0" for "v1.3.0-rc.1" and both "patch" and "minor". Pseudo-versions can't be bumped, as they don't point to
+// any tag.
+func BumpVersion(version, part string) (string, error) {
+	if !semver.IsValid(version) {
+		return "", errors.Newf("can't bump version %q; not a valid semantic version", version)
+	}
+	if module.IsPseudoVersion(version) {
+		return "", errors.Newf("can't bump pseudo-version %q; it's not tied to any tag", version)
+	}
+
+	core := strings.TrimPrefix(semver.Canonical(version), "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	var nums [3]int
+	for i, n := range strings.Split(core, ".") {
+		nums[i], _ = strconv.Atoi(n)
+	}
+
+	prerelease := semver.Prerelease(version) != ""
+	switch part {
+	case "patch":
+		if !prerelease {
+			nums[2]++
+		}
+	case "minor":
+		if !prerelease || nums[2] != 0 {
+			nums[1], nums[2] = nums[1]+1, 0
+		}
+	case "major":
+		if !prerelease || nums[1] != 0 || nums[2] != 0 {
+			nums[0], nums[1], nums[2] = nums[0]+1, 0, 0
+		}
+	default:
+		return "", errors.Newf("unknown version part %q; expected patch, minor or major", part)
+	}
+
+	bumped := fmt.Sprintf("v%d.%d.%d", nums[0], nums[1], nums[2])
+	if semver.Build(version) == "+incompatible" {
+		bumped += "+incompatible"
+	}
+	return bumped, nil
+}
@@ -0,0 +1,238 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package bingo
+
+import (
+	"testing"
+
+	"github.com/bwplotka/bingo/pkg/mod"
+	"github.com/efficientgo/core/testutil"
+)
+
+func TestNormalizeVersion(t *testing.T) {
+	for _, tcase := range []struct {
+		version string
+
+		expected    string
+		expectedErr string
+	}{
+		{version: "", expectedErr: "empty version"},
+		{version: "latest", expected: "latest"},
+		{version: "v1.2.3", expected: "v1.2.3"},
+		{version: "v1.2", expected: "v1.2"},
+		{version: "v0.2.5-rc.1214", expected: "v0.2.5-rc.1214"},
+		{version: "v2.4.3+incompatible", expected: "v2.4.3+incompatible"},
+		{version: "v1.2.3+build.1", expected: "v1.2.3"},
+		{version: "v0.0.0-20210220032951-036812b2e83c", expected: "v0.0.0-20210220032951-036812b2e83c"},
+		{version: "bb92924b84d060515f8eb35f428a8fd816c1d938", expected: "bb92924b84d060515f8eb35f428a8fd816c1d938"},
+		{version: "main", expected: "main"},
+		{version: "version1", expected: "version1"},
+		{version: "release/1.x", expected: "release/1.x"},
+		{version: "upgrade", expected: "upgrade"},
+		{version: "patch", expected: "patch"},
+		{version: "<v1.2.3", expected: "<v1.2.3"},
+		{version: "<=v1.2.3", expected: "<=v1.2.3"},
+		{version: ">v1.2", expected: ">v1.2"},
+		{version: ">=v0.2.5-rc.1214", expected: ">=v0.2.5-rc.1214"},
+		{version: ">=1.2.3", expectedErr: `malformed version query ">=1.2.3"; expected comparison with semantic version, e.g. '>=v1.2.3'`},
+		{version: "<>v1.2.3", expectedErr: `malformed version query "<>v1.2.3"; expected comparison with semantic version, e.g. '>=v1.2.3'`},
+		{version: "v1.2.3.4", expectedErr: `malformed version "v1.2.3.4"; not a valid semantic version`},
+		{version: "-v1", expectedErr: `malformed version "-v1"; expected semantic version, pseudo-version, 'latest' or revision`},
+		{version: "1.2 3", expectedErr: `malformed version "1.2 3"; expected semantic version, pseudo-version, 'latest' or revision`},
+	} {
+		t.Run(tcase.version, func(t *testing.T) {
+			v, err := NormalizeVersion(tcase.version)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, v)
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tcase := range []struct {
+		a, b string
+
+		expected    int
+		expectedErr string
+	}{
+		{a: "v1.2.3", b: "v1.2.3", expected: 0},
+		{a: "v1.2.3", b: "v1.10.0", expected: -1},
+		{a: "v2.0.0", b: "v1.10.0", expected: 1},
+		{a: "v1.2", b: "v1.2.0", expected: 0},
+		{a: "v1.0.0-rc.1", b: "v1.0.0", expected: -1},
+		{a: "v2.4.3+incompatible", b: "v2.4.3", expected: 0},
+		{a: "v0.0.0-20210220032951-036812b2e83c", b: "v0.1.0", expected: -1},
+		{a: "v0.0.0-20210220032951-036812b2e83c", b: "v0.0.0-20200101000000-aaaaaaaaaaaa", expected: 1},
+		{a: "v1.2.4-0.20210220032951-036812b2e83c", b: "v1.2.3", expected: 1},
+		{a: "latest", b: "v1.2.3", expectedErr: `can't compare version "latest"; not a valid semantic version`},
+		{a: "v1.2.3", b: "main", expectedErr: `can't compare version "main"; not a valid semantic version`},
+	} {
+		t.Run(tcase.a+" "+tcase.b, func(t *testing.T) {
+			c, err := CompareVersions(tcase.a, tcase.b)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, c)
+		})
+	}
+}
+
+func TestIsRetracted(t *testing.T) {
+	retracts := []mod.RetractDirective{
+		{VersionInterval: mod.VersionInterval{Low: "v1.0.0", High: "v1.0.0"}, Rationale: "Published accidentally."},
+		{VersionInterval: mod.VersionInterval{Low: "v1.2.0", High: "v1.2.5"}},
+	}
+	for _, tcase := range []struct {
+		version string
+
+		expectedOk        bool
+		expectedRationale string
+	}{
+		{version: "v1.0.0", expectedOk: true, expectedRationale: "Published accidentally."},
+		{version: "v1.2.0", expectedOk: true},
+		{version: "v1.2.3", expectedOk: true},
+		{version: "v1.2.5", expectedOk: true},
+		{version: "v0.9.0"},
+		{version: "v1.0.1"},
+		{version: "v1.2.6"},
+		{version: "latest"},
+	} {
+		t.Run(tcase.version, func(t *testing.T) {
+			rationale, ok := IsRetracted(tcase.version, retracts)
+			testutil.Equals(t, tcase.expectedOk, ok)
+			testutil.Equals(t, tcase.expectedRationale, rationale)
+		})
+	}
+	_, ok := IsRetracted("v1.0.0", nil)
+	testutil.Assert(t, !ok)
+}
+
+func TestIsPseudoVersion(t *testing.T) {
+	for _, tcase := range []struct {
+		version  string
+		expected bool
+	}{
+		// No earlier tag.
+		{version: "v0.0.0-20210220032951-036812b2e83c", expected: true},
+		{version: "v2.0.0-20210220032951-036812b2e83c+incompatible", expected: true},
+		// Latest tag is a pre-release.
+		{version: "v1.2.3-rc.1.0.20210220032951-036812b2e83c", expected: true},
+		// Latest tag is a release.
+		{version: "v1.2.4-0.20210220032951-036812b2e83c", expected: true},
+		{version: "v1.2.3"},
+		{version: "v1.2.3-rc.1"},
+		{version: "v2.4.3+incompatible"},
+		{version: "main"},
+		{version: "latest"},
+		{version: ""},
+	} {
+		t.Run(tcase.version, func(t *testing.T) {
+			testutil.Equals(t, tcase.expected, IsPseudoVersion(tcase.version))
+		})
+	}
+}
+
+func TestIsIncompatible(t *testing.T) {
+	for _, tcase := range []struct {
+		version  string
+		expected bool
+	}{
+		{version: "v2.0.0+incompatible", expected: true},
+		{version: "v2.4.3+incompatible", expected: true},
+		{version: "v2.0.0-20210220032951-036812b2e83c+incompatible", expected: true},
+		{version: "v2.0.0"},
+		{version: "v1.2.3"},
+		{version: "v0.0.0-20210220032951-036812b2e83c"},
+		{version: "+incompatible"},
+		{version: "latest"},
+		{version: ""},
+	} {
+		t.Run(tcase.version, func(t *testing.T) {
+			testutil.Equals(t, tcase.expected, IsIncompatible(tcase.version))
+		})
+	}
+}
+
+func TestResolveLatest(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.2.0", "v1.10.1", "v1.11.0-rc.0", "v2.0.0", "v2.1.0+incompatible", "v2.2.0-rc.1", "main"}
+	for _, tcase := range []struct {
+		modulePath     string
+		currentVersion string
+		tags           []string
+
+		expected    string
+		expectedErr string
+	}{
+		{modulePath: "github.com/org/repo", currentVersion: "v1.2.0", tags: tags, expected: "v1.10.1"},
+		{modulePath: "github.com/org/repo", currentVersion: "v1.10.1", tags: tags, expected: "v1.10.1"},
+		{modulePath: "github.com/org/repo", currentVersion: "v1.11.0-rc.1", tags: tags, expected: "v1.11.0-rc.1"},
+		{modulePath: "github.com/org/repo", currentVersion: "v0.0.0-20210220032951-036812b2e83c", tags: tags, expected: "v0.0.0-20210220032951-036812b2e83c"},
+		{modulePath: "github.com/org/repo", currentVersion: "v1.0.0-20210220032951-036812b2e83c", tags: tags, expected: "v1.10.1"},
+		{modulePath: "github.com/org/repo", currentVersion: "v2.0.0+incompatible", tags: tags, expected: "v2.1.0+incompatible"},
+		{modulePath: "github.com/org/repo/v2", currentVersion: "v2.0.0", tags: []string{"v2.0.0", "v2.2.0-rc.1", "v3.0.0"}, expected: "v2.0.0"},
+		{modulePath: "github.com/org/repo/v3", currentVersion: "v3.0.0-rc.0", tags: []string{"v2.0.0", "v3.0.0-rc.0", "v3.0.0-rc.1"}, expected: "v3.0.0-rc.1"},
+		{modulePath: "github.com/org/repo", currentVersion: "v1.2.0", expected: "v1.2.0"},
+		{modulePath: "github.com/org/repo", currentVersion: "main", tags: tags, expectedErr: `can't resolve latest version for github.com/org/repo; current version "main" is not a valid semantic version`},
+		{modulePath: "github.com/org/repo/v2", currentVersion: "v1.2.0", tags: tags, expectedErr: "can't resolve latest version for github.com/org/repo/v2: version \"v1.2.0\" invalid: should be v2, not v1"},
+	} {
+		t.Run(tcase.modulePath+"@"+tcase.currentVersion, func(t *testing.T) {
+			v, err := ResolveLatest(tcase.modulePath, tcase.currentVersion, tcase.tags)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, v)
+		})
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	for _, tcase := range []struct {
+		version, part string
+
+		expected    string
+		expectedErr string
+	}{
+		{version: "v1.2.3", part: "patch", expected: "v1.2.4"},
+		{version: "v1.2.3", part: "minor", expected: "v1.3.0"},
+		{version: "v1.2.3", part: "major", expected: "v2.0.0"},
+		{version: "v1.2", part: "patch", expected: "v1.2.1"},
+		{version: "v0.9.9", part: "minor", expected: "v0.10.0"},
+		{version: "v1.2.3-rc.1", part: "patch", expected: "v1.2.3"},
+		{version: "v1.2.3-rc.1", part: "minor", expected: "v1.3.0"},
+		{version: "v1.2.3-rc.1", part: "major", expected: "v2.0.0"},
+		{version: "v1.3.0-rc.1", part: "patch", expected: "v1.3.0"},
+		{version: "v1.3.0-rc.1", part: "minor", expected: "v1.3.0"},
+		{version: "v1.3.0-rc.1", part: "major", expected: "v2.0.0"},
+		{version: "v2.0.0-rc.1", part: "patch", expected: "v2.0.0"},
+		{version: "v2.0.0-rc.1", part: "minor", expected: "v2.0.0"},
+		{version: "v2.0.0-rc.1", part: "major", expected: "v2.0.0"},
+		{version: "v2.4.3+incompatible", part: "major", expected: "v3.0.0+incompatible"},
+		{version: "v1.2.3", part: "build", expectedErr: `unknown version part "build"; expected patch, minor or major`},
+		{version: "main", part: "patch", expectedErr: `can't bump version "main"; not a valid semantic version`},
+		{version: "v0.0.0-20210220032951-036812b2e83c", part: "patch", expectedErr: `can't bump pseudo-version "v0.0.0-20210220032951-036812b2e83c"; it's not tied to any tag`},
+		{version: "v1.2.4-0.20210220032951-036812b2e83c", part: "minor", expectedErr: `can't bump pseudo-version "v1.2.4-0.20210220032951-036812b2e83c"; it's not tied to any tag`},
+	} {
+		t.Run(tcase.version+" "+tcase.part, func(t *testing.T) {
+			v, err := BumpVersion(tcase.version, tcase.part)
+			if tcase.expectedErr != "" {
+				testutil.NotOk(t, err)
+				testutil.Equals(t, tcase.expectedErr, err.Error())
+				return
+			}
+			testutil.Ok(t, err)
+			testutil.Equals(t, tcase.expected, v)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package mod
+
+import "os"
+
+// Locker provides advisory locking of module files opened for edits. File holds the lock from open until Close, so
+// concurrent processes editing the same module file (e.g. parallel bingo get of tools sharing one module file) never
+// base their edits on outdated content and no update is lost. Given file is the lock file, see LockFilePath.
+type Locker interface {
+	Lock(f *os.File) error
+	Unlock(f *os.File) error
+}
+
+// DefaultLocker is used by OpenFile and OpenFileForRead. It uses flock(2) on systems supporting it and does not
+// lock anywhere else.
+var DefaultLocker = newDefaultLocker()
+
+type noopLocker struct{}
+
+func (noopLocker) Lock(*os.File) error   { return nil }
+func (noopLocker) Unlock(*os.File) error { return nil }
@@ -0,0 +1,28 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package mod
+
+import (
+	"os"
+	"syscall"
+)
+
+type flockLocker struct{}
+
+func newDefaultLocker() Locker { return flockLocker{} }
+
+func (flockLocker) Lock(f *os.File) error   { return flock(f, syscall.LOCK_EX) }
+func (flockLocker) Unlock(f *os.File) error { return flock(f, syscall.LOCK_UN) }
+
+func flock(f *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
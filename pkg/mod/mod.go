@@ -4,8 +4,12 @@
 package mod
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/efficientgo/core/errcapture"
 	"github.com/efficientgo/core/errors"
@@ -17,42 +21,167 @@ import (
 type File struct {
 	path string
 
-	f *os.File
-	m *modfile.File
+	// onDisk is false if File is not backed by file on disk.
+	onDisk bool
+	// lockFile is the open lock file (see LockFilePath) of File opened for edits, nil otherwise.
+	lockFile *os.File
+	locker   Locker
+	m        *modfile.File
+
+	// crlf is true if the file uses mostly CRLF line endings, which are then preserved on flush.
+	crlf bool
+	// sortRequires is true if direct requires are sorted by module path on flush.
+	sortRequires bool
+	// toolchain is the toolchain directive (Go 1.21+), which is not supported by the modfile parser version we use.
+	// It's taken out before parsing and put back after the go directive on flush.
+	toolchain string
 }
 
-// OpenFile opens mod file for edits in place.
+// OpenFile opens mod file for edits in place. File is locked with DefaultLocker from open until Close, so the whole
+// read-modify-write of concurrent editors (e.g. parallel bingo get of tools sharing one module file) is serialized and
+// no update is lost. Opening the same module file for edits again before Close blocks, also within one process.
 // It's a caller responsibility to Close the file when not using anymore.
 func OpenFile(modFile string) (_ *File, err error) {
+	return OpenFileWithLocker(modFile, DefaultLocker)
+}
+
+// OpenFileWithLocker is like OpenFile, but File is locked with the given Locker. The lock is taken on the lock file
+// kept next to the module file, see LockFilePath.
+// It's a caller responsibility to Close the file when not using anymore.
+func OpenFileWithLocker(modFile string, locker Locker) (_ *File, err error) {
+	// Only check if module file can be edited, it's replaced on each write.
 	f, err := os.OpenFile(modFile, os.O_RDWR, os.ModePerm)
 	if err != nil {
 		return nil, err
 	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	f, err = os.OpenFile(LockFilePath(modFile), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, errors.Wrap(err, "open lock file")
+	}
 	defer func() {
 		if err != nil {
-			errcapture.Do(&err, f.Close, "close")
+			errcapture.Do(&err, f.Close, "close lock file")
 		}
 	}()
 
-	mf := &File{f: f, path: modFile}
+	if err := locker.Lock(f); err != nil {
+		return nil, errors.Wrap(err, "lock")
+	}
+	defer func() {
+		if err != nil {
+			errcapture.Do(&err, func() error { return locker.Unlock(f) }, "unlock")
+		}
+	}()
+
+	mf := &File{onDisk: true, lockFile: f, locker: locker, path: modFile}
 	return mf, mf.Reload()
 }
 
-// OpenFileForRead opens mod file for reads.
+// OpenFileForRead opens mod file for reads. Reads are not locked, as module files are always written atomically, so
+// readers see either the old or the new content, never partial one. It works for module files in read-only
+// directories too, e.g. in the module cache.
 // It's a caller responsibility to Close the file when not using anymore.
 func OpenFileForRead(modFile string) (_ FileForRead, err error) {
-	f, err := os.OpenFile(modFile, os.O_RDONLY, os.ModePerm)
+	mf := &File{onDisk: true, path: modFile}
+	return mf, mf.Reload()
+}
+
+// LockFilePath returns path of the lock file used to guard edits of the given module file, e.g. ".bingo/foo.mod.lock"
+// for ".bingo/foo.mod". Module file itself can't be locked, as it's replaced on each write, see OpenFile. The lock file
+// is created on first edit and never removed, so all processes always lock the same file.
+func LockFilePath(modFile string) string {
+	return modFile + ".lock"
+}
+
+// Parse parses mod file content from the given reader into File that is not backed by any file on disk. All edits
+// are kept in memory, use WriteTo to get the formatted content. The name is used only for diagnostics.
+func Parse(name string, r io.Reader) (_ *File, err error) {
+	b, err := io.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "read")
 	}
-	defer func() {
-		if err != nil {
-			errcapture.Do(&err, f.Close, "close")
+
+	mf := &File{path: name}
+	return mf, mf.parse(b)
+}
+
+// ParseLenient is like Parse, but it does not stop on the first broken line. Instead, it collects errors for each
+// line that can't be parsed and parses the rest of the content as if these lines were empty. Returned File is nil
+// only if content could not be read or recovered at all.
+func ParseLenient(name string, r io.Reader) (*File, []error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "read")}
+	}
+
+	var errs []error
+	content, toolchain, err := cutToolchain(name, b)
+	if err != nil {
+		errs = append(errs, err)
+		content = b
+	}
+	lines := bytes.Split(content, []byte("\n"))
+	for {
+		m, err := modfile.Parse(name, bytes.Join(lines, []byte("\n")), nil)
+		if err == nil {
+			mf := &File{path: name, m: m, crlf: isCRLF(b), toolchain: toolchain}
+			return mf, errs
 		}
-	}()
 
-	mf := &File{f: f, path: modFile}
-	return mf, mf.Reload()
+		var errList modfile.ErrorList
+		if !errors.As(err, &errList) {
+			return nil, append(errs, err)
+		}
+		removed := false
+		for i := range errList {
+			e := errList[i]
+			errs = append(errs, &e)
+
+			if l := e.Pos.Line - 1; l >= 0 && l < len(lines) && len(bytes.TrimSpace(lines[l])) > 0 {
+				lines[l] = nil
+				removed = true
+			}
+		}
+		if !removed {
+			// Can't recover by removing broken lines, give up.
+			return nil, errs
+		}
+	}
+}
+
+// Diagnostic describes single problem found when parsing module file, e.g. for pointing at it in an editor.
+type Diagnostic struct {
+	// Line and Col are 1-based position of the problem. Col is 0 if the column is not known, Line is 0 if the problem
+	// is not related to any line.
+	Line, Col int
+	// Message is the error message without position, e.g. "usage: require module/path v1.2.3".
+	Message string
+}
+
+// ParseWithDiagnostics is like Parse, but if module file content can't be parsed, it also returns diagnostics with
+// position of each problem found by the parser, together with the error Parse would return.
+func ParseWithDiagnostics(name string, r io.Reader) (*File, []Diagnostic, error) {
+	mf, err := Parse(name, r)
+	if err == nil {
+		return mf, nil, nil
+	}
+
+	var errList modfile.ErrorList
+	if !errors.As(err, &errList) {
+		return nil, nil, err
+	}
+	diags := make([]Diagnostic, 0, len(errList))
+	for _, e := range errList {
+		d := Diagnostic{Line: e.Pos.Line, Col: e.Pos.LineRune}
+		e.Filename, e.Pos = "", modfile.Position{}
+		d.Message = e.Error()
+		diags = append(diags, d)
+	}
+	return nil, diags, err
 }
 
 type FileForRead interface {
@@ -62,6 +191,7 @@ type FileForRead interface {
 	Module() (path string, comment string)
 	Comments() (comments []string)
 	GoVersion() string
+	Toolchain() string
 	RequireDirectives() []RequireDirective
 	ReplaceDirectives() []ReplaceDirective
 	ExcludeDirectives() []ExcludeDirective
@@ -70,24 +200,85 @@ type FileForRead interface {
 	Close() error
 }
 
-// Reload re-parses module file from the latest state on the disk.
-func (mf *File) Reload() (err error) {
-	if _, err := mf.f.Seek(0, 0); err != nil {
-		return errors.Wrap(err, "seek")
+// Reload re-parses module file from the latest state on the disk, e.g. after other tool like go edited it. It's a noop
+// for File not backed by file on disk.
+func (mf *File) Reload() error {
+	if !mf.onDisk {
+		return nil
+	}
+	b, err := os.ReadFile(mf.path)
+	if err != nil {
+		return err
 	}
+	return mf.parse(b)
+}
 
-	mf.m, err = parseModFileOrReader(mf.path, mf.f)
+func (mf *File) parse(b []byte) (err error) {
+	mf.crlf = isCRLF(b)
+	b, mf.toolchain, err = cutToolchain(mf.path, b)
+	if err != nil {
+		return err
+	}
+	mf.m, err = parseModFile(mf.path, b)
 	return err
 }
 
+// cutToolchain returns module file content with toolchain directive line blanked, so line numbers stay the same, and
+// the toolchain name, e.g. "go1.21.3".
+func cutToolchain(modFile string, b []byte) (_ []byte, toolchain string, _ error) {
+	lines := bytes.Split(b, []byte("\n"))
+	for i, l := range lines {
+		f := strings.Fields(string(l))
+		if len(f) == 0 || f[0] != "toolchain" {
+			continue
+		}
+		if len(f) != 2 && (len(f) < 3 || !strings.HasPrefix(f[2], "//")) {
+			return nil, "", errors.Newf("%s:%d: usage: toolchain name", modFile, i+1)
+		}
+		if toolchain != "" {
+			return nil, "", errors.Newf("%s:%d: repeated toolchain statement", modFile, i+1)
+		}
+		toolchain = f[1]
+		lines[i] = nil
+	}
+	if toolchain == "" {
+		return b, "", nil
+	}
+	return bytes.Join(lines, []byte("\n")), toolchain, nil
+}
+
 func (mf *File) Filepath() string {
 	return mf.path
 }
 
-// Close closes file.
+// Close releases the lock of File opened for edits.
 // TODO(bwplotka): Ensure other methods will return error on use after Close.
-func (mf *File) Close() error {
-	return mf.f.Close()
+func (mf *File) Close() (err error) {
+	if mf.lockFile == nil {
+		return nil
+	}
+	f := mf.lockFile
+	mf.lockFile = nil
+	defer errcapture.Do(&err, f.Close, "close lock file")
+	return errors.Wrap(mf.locker.Unlock(f), "unlock")
+}
+
+// SetContent replaces the whole module file with the given content in one write, e.g. to apply edits batched on
+// a copy created with Parse. Content has to be a valid module file.
+func (mf *File) SetContent(b []byte) error {
+	// Parse separately, so File stays usable on invalid content.
+	next := &File{path: mf.path}
+	if err := next.parse(b); err != nil {
+		return err
+	}
+	mf.m, mf.crlf, mf.toolchain = next.m, next.crlf, next.toolchain
+	return mf.flush()
+}
+
+// WriteTo writes formatted module file content to the given writer.
+func (mf *File) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(mf.format())
+	return int64(n), err
 }
 
 func (mf *File) Module() (path string, comment string) {
@@ -95,25 +286,49 @@ func (mf *File) Module() (path string, comment string) {
 		return "", ""
 	}
 	if len(mf.m.Module.Syntax.Comment().Suffix) > 0 {
-		comment = mf.m.Module.Syntax.Comment().Suffix[0].Token[3:]
+		comment = commentText(mf.m.Module.Syntax.Comment().Suffix[0].Token)
 	}
 	return mf.m.Module.Mod.Path, comment
 }
 
 func (mf *File) SetModule(path string, comment string) error {
+	added := mf.m.Module == nil
 	if err := mf.m.AddModuleStmt(path); err != nil {
 		return err
 	}
+	if stmts := mf.m.Syntax.Stmt; added && len(stmts) > 1 {
+		// New module line is appended at the end, move it to the top where go puts it, after leading comments if any.
+		at := 0
+		for at < len(stmts)-1 {
+			if _, ok := stmts[at].(*modfile.CommentBlock); !ok {
+				break
+			}
+			at++
+		}
+		moved := append([]modfile.Expr{}, stmts[:at]...)
+		moved = append(moved, stmts[len(stmts)-1])
+		mf.m.Syntax.Stmt = append(moved, stmts[at:len(stmts)-1]...)
+	}
 
-	mf.m.Module.Syntax.Suffix = append(mf.m.Module.Syntax.Suffix, modfile.Comment{Suffix: true, Token: "// " + comment})
+	// Replace, not append, so module line does not accumulate comments on each set.
+	mf.m.Module.Syntax.Suffix = nil
+	if comment != "" {
+		mf.m.Module.Syntax.Suffix = []modfile.Comment{{Suffix: true, Token: "// " + comment}}
+	}
 
 	return mf.flush()
 }
 
+// commentText returns text of the given comment token, without "//" and surrounding spaces. It does not assume exactly
+// one space after "//", as hand edited files can have none or more.
+func commentText(token string) string {
+	return strings.TrimSpace(strings.TrimPrefix(token, "//"))
+}
+
 func (mf *File) Comments() (comments []string) {
 	for _, e := range mf.m.Syntax.Stmt {
 		for _, c := range e.Comment().Before {
-			comments = append(comments, c.Token[3:])
+			comments = append(comments, commentText(c.Token))
 		}
 	}
 	return comments
@@ -139,21 +354,126 @@ func (mf *File) SetGoVersion(version string) error {
 	return mf.flush()
 }
 
+// Toolchain returns toolchain from the toolchain directive (Go 1.21+), e.g. "go1.21.3", or empty string if there is
+// none.
+func (mf *File) Toolchain() string {
+	return mf.toolchain
+}
+
+// SetToolchain sets the toolchain directive, e.g. "go1.21.3", put right after the go directive. Empty toolchain removes
+// the directive.
+func (mf *File) SetToolchain(toolchain string) error {
+	if toolchain != "" && toolchain != "default" && !strings.HasPrefix(toolchain, "go1") {
+		return errors.Newf("invalid toolchain %q, expected e.g. go1.21.3 or default", toolchain)
+	}
+	mf.toolchain = toolchain
+	return mf.flush()
+}
+
+// SetSortRequires enables or disables sorting direct requires by module path each time changes are written, so module
+// files with many direct requires have stable order no matter the order of edits. Indirect requires keep their order.
+func (mf *File) SetSortRequires(enabled bool) {
+	mf.sortRequires = enabled
+}
+
+// sortDirectRequires reorders lines of direct requires by module path in place, so their comments move with them and
+// positions of indirect requires and blocks are kept.
+func (mf *File) sortDirectRequires() {
+	type requireLine struct {
+		path string
+		line modfile.Line
+	}
+	var (
+		lines  []*modfile.Line
+		sorted []requireLine
+	)
+	for _, r := range mf.m.Require {
+		if !r.Indirect && r.Syntax != nil {
+			lines = append(lines, r.Syntax)
+			sorted = append(sorted, requireLine{path: r.Mod.Path, line: *r.Syntax})
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].path < sorted[j].path })
+	for i, l := range lines {
+		// Lines outside of require block have "require" verb token, keep it where it was.
+		inBlock := len(l.Token) == 0 || l.Token[0] != "require"
+		*l = sorted[i].line
+		if verb := len(l.Token) > 0 && l.Token[0] == "require"; inBlock && verb {
+			l.Token = l.Token[1:]
+		} else if !inBlock && !verb {
+			l.Token = append([]string{"require"}, l.Token...)
+		}
+	}
+}
+
 // Flush saves all changes made to parsed syntax and reloads the parsed file.
-func (mf *File) flush() error {
+func (mf *File) flush() (err error) {
+	if mf.sortRequires {
+		mf.sortDirectRequires()
+	}
 	mf.m.Cleanup()
-	newB := modfile.Format(mf.m.Syntax)
-	if err := mf.f.Truncate(0); err != nil {
-		return errors.Wrap(err, "truncate")
+	newB := mf.format()
+	if !mf.onDisk {
+		// Nothing to save, but re-parse, so syntax gets rebuilt. It might change due to format.
+		return mf.parse(newB)
+	}
+	if mf.lockFile == nil {
+		return errors.Newf("%s: module file is opened for reads only", mf.path)
+	}
+
+	// Lock file is not replaced by the write below, so the lock taken on open is held across the rename.
+	// Never truncate in place, so crash or failed write does not leave empty module file behind.
+	if err := writeFileAtomic(mf.path, newB); err != nil {
+		return err
+	}
+
+	// Re-parse what was written, so syntax gets rebuilt. It might change due to format. No need to read it back.
+	return mf.parse(newB)
+}
+
+// createTemp is used for atomic writes. Replaced in tests.
+var createTemp = os.CreateTemp
+
+// writeFileAtomic writes b to a temporary file in the same directory and renames it over the given file, keeping
+// its mode. Readers see either the old or the new content, never partial one.
+func writeFileAtomic(name string, b []byte) (err error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
 	}
-	if _, err := mf.f.Seek(0, 0); err != nil {
-		return errors.Wrap(err, "seek")
+
+	tmp, err := createTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "create tmp")
 	}
-	if _, err := mf.f.Write(newB); err != nil {
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(b); err != nil {
 		return errors.Wrap(err, "write")
 	}
-	// Reload, so syntax gets rebuilt. It might change due to format.
-	return mf.Reload()
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "close")
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode()); err != nil {
+		return errors.Wrap(err, "chmod")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), name), "rename")
+}
+
+func (mf *File) format() []byte {
+	b := modfile.Format(mf.m.Syntax)
+	if mf.toolchain != "" {
+		b = insertToolchain(b, mf.toolchain)
+	}
+	if mf.crlf {
+		b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+	}
+	return b
 }
 
 type RequireDirective struct {
@@ -173,9 +493,10 @@ func (mf *File) RequireDirectives() []RequireDirective {
 			Indirect: r.Indirect,
 		}
 		if len(r.Syntax.Suffix) > 0 {
-			ret[i].ExtraSuffixComment = r.Syntax.Suffix[0].Token[3:]
+			ret[i].ExtraSuffixComment = commentText(r.Syntax.Suffix[0].Token)
 			if r.Indirect {
-				ret[i].ExtraSuffixComment = r.Syntax.Suffix[0].Token[11:]
+				// Same as modfile, which treats "indirect" and "indirect; <other comment>" as indirect marker.
+				ret[i].ExtraSuffixComment = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(ret[i].ExtraSuffixComment, "indirect"), ";"))
 			}
 		}
 	}
@@ -201,11 +522,42 @@ func (mf *File) SetRequireDirectives(directives ...RequireDirective) (err error)
 	return mf.flush()
 }
 
+// SetRequireComment sets the suffix comment of the require statement for the given module path, keeping the
+// indirect marker, if any. Empty comment removes the comment.
+func (mf *File) SetRequireComment(path string, comment string) error {
+	for _, r := range mf.m.Require {
+		if r.Mod.Path != path {
+			continue
+		}
+
+		token := ""
+		switch {
+		case r.Indirect && comment != "":
+			token = "// indirect; " + comment
+		case r.Indirect:
+			token = "// indirect"
+		case comment != "":
+			token = "// " + comment
+		}
+		r.Syntax.Suffix = nil
+		if token != "" {
+			r.Syntax.Suffix = []modfile.Comment{{Suffix: true, Token: token}}
+		}
+		return mf.flush()
+	}
+	return errors.Newf("no require of %s module found in %s", path, mf.path)
+}
+
 type ReplaceDirective struct {
 	Old module.Version
 	New module.Version
 }
 
+// IsLocal returns true if module is replaced by local directory, instead of other module.
+func (r ReplaceDirective) IsLocal() bool {
+	return r.New.Version == "" && modfile.IsDirectoryPath(r.New.Path)
+}
+
 func (mf *File) ReplaceDirectives() []ReplaceDirective {
 	ret := make([]ReplaceDirective, len(mf.m.Replace))
 	for i, r := range mf.m.Replace {
@@ -296,13 +648,8 @@ func (mf *File) SetRetractDirectives(directives ...RetractDirective) (err error)
 	return mf.flush()
 }
 
-// parseModFileOrReader parses any module file or reader allowing to read it's content.
-func parseModFileOrReader(modFile string, r io.Reader) (*modfile.File, error) {
-	b, err := readAllFileOrReader(modFile, r)
-	if err != nil {
-		return nil, errors.Wrap(err, "read")
-	}
-
+// parseModFile parses any module file content.
+func parseModFile(modFile string, b []byte) (*modfile.File, error) {
 	m, err := modfile.Parse(modFile, b, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "parse")
@@ -310,9 +657,35 @@ func parseModFileOrReader(modFile string, r io.Reader) (*modfile.File, error) {
 	return m, nil
 }
 
-func readAllFileOrReader(file string, r io.Reader) (b []byte, err error) {
-	if r != nil {
-		return io.ReadAll(r)
+// insertToolchain puts toolchain directive into formatted module file content after the go directive, or after the
+// module directive if there is no go directive, the same way go does.
+func insertToolchain(b []byte, toolchain string) []byte {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	at := -1
+	for i, l := range lines {
+		switch {
+		case bytes.HasPrefix(l, []byte("go ")):
+			at = i
+		case bytes.HasPrefix(l, []byte("module ")) && at == -1:
+			at = i
+		}
+	}
+	directive := []byte("\ntoolchain " + toolchain + "\n")
+	if at == -1 {
+		return append(directive[1:], b...)
+	}
+	ret := make([]byte, 0, len(b)+len(directive))
+	for i, l := range lines {
+		ret = append(ret, l...)
+		if i == at {
+			ret = append(ret, directive...)
+		}
 	}
-	return os.ReadFile(file)
+	return ret
+}
+
+// isCRLF returns true if most of the lines in the given content are terminated with CRLF.
+func isCRLF(b []byte) bool {
+	crlf := bytes.Count(b, []byte("\r\n"))
+	return crlf > 0 && 2*crlf >= bytes.Count(b, []byte("\n"))
 }
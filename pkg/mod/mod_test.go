@@ -4,10 +4,15 @@
 package mod
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/efficientgo/core/errors"
 	"github.com/efficientgo/core/testutil"
 	"golang.org/x/mod/module"
 )
@@ -195,3 +200,459 @@ retract [v0.9.0, v1.0.0]
 		testutil.Equals(t, "I don't know", retractDirectives[0].Rationale)
 	})
 }
+
+func TestFile_CRLF(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _\r\n\r\ngo 1.17\r\n\r\nrequire github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus\r\n"), os.ModePerm))
+
+	mf, err := OpenFile(testFile)
+	testutil.Ok(t, err)
+
+	reqDirectives := mf.RequireDirectives()
+	testutil.Equals(t, 1, len(reqDirectives))
+	testutil.Equals(t, "cmd/prometheus", reqDirectives[0].ExtraSuffixComment)
+
+	testutil.Ok(t, mf.SetModule("_", "yolo"))
+	testutil.Ok(t, mf.SetRequireDirectives(RequireDirective{Module: module.Version{Path: "my/module", Version: "v1.0.0"}, ExtraSuffixComment: "yolo"}))
+	testutil.Ok(t, mf.Close())
+
+	expectContent(t, "module _ // yolo\r\n\r\ngo 1.17\r\n\r\nrequire my/module v1.0.0 // yolo\r\n", testFile)
+}
+
+func TestParse_CommentSpacing(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		comment  string
+		expected string
+	}{
+		{comment: "// foo", expected: "foo"},
+		{comment: "//foo", expected: "foo"},
+		{comment: "//  foo  ", expected: "foo"},
+		{comment: "//", expected: ""},
+		{comment: "// indirect", expected: ""},
+		{comment: "//indirect; foo", expected: "foo"},
+		{comment: "// indirect;  foo", expected: "foo"},
+	} {
+		t.Run(tcase.comment, func(t *testing.T) {
+			mf, err := Parse("test.mod", strings.NewReader("module _ "+tcase.comment+"\n\n"+tcase.comment+"\ngo 1.17\n\nrequire my/module v1.0.0 "+tcase.comment+"\n"))
+			testutil.Ok(t, err)
+
+			_, comment := mf.Module()
+			testutil.Equals(t, strings.TrimSpace(strings.TrimPrefix(tcase.comment, "//")), comment)
+			testutil.Equals(t, []string{comment}, mf.Comments())
+			testutil.Equals(t, tcase.expected, mf.RequireDirectives()[0].ExtraSuffixComment)
+		})
+	}
+}
+
+func TestFile_FailedWriteKeepsOriginal(t *testing.T) {
+	// Not parallel, as it replaces package createTemp.
+	dir := t.TempDir()
+	testFile := filepath.Join(dir, "test.mod")
+	content := "module _\n\ngo 1.17\n\nrequire my/module v1.0.0\n"
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), 0600))
+
+	mf, err := OpenFile(testFile)
+	testutil.Ok(t, err)
+	t.Cleanup(func() { testutil.Ok(t, mf.Close()) })
+
+	createTemp = func(dir, pattern string) (*os.File, error) {
+		f, err := os.CreateTemp(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		// Closed file fails on write, like e.g. full disk.
+		return f, f.Close()
+	}
+	t.Cleanup(func() { createTemp = os.CreateTemp })
+
+	testutil.NotOk(t, mf.SetModule("_", "yolo"))
+	expectContent(t, content, testFile)
+
+	// No leftovers.
+	entries, err := os.ReadDir(dir)
+	testutil.Ok(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	testutil.Equals(t, []string{"test.mod", "test.mod.lock"}, names)
+	fi, err := os.Stat(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, os.FileMode(0600), fi.Mode())
+
+	createTemp = os.CreateTemp
+	testutil.Ok(t, mf.SetModule("_", "yolo"))
+	expectContent(t, "module _ // yolo\n\ngo 1.17\n\nrequire my/module v1.0.0\n", testFile)
+	fi, err = os.Stat(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, os.FileMode(0600), fi.Mode())
+}
+
+func TestFile_SetContent(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.17\n\nrequire my/module v1.0.0\n"), os.ModePerm))
+
+	mf, err := OpenFile(testFile)
+	testutil.Ok(t, err)
+	defer func() { testutil.Ok(t, mf.Close()) }()
+
+	testutil.NotOk(t, mf.SetContent([]byte("require")))
+	expectContent(t, "module _\n\ngo 1.17\n\nrequire my/module v1.0.0\n", testFile)
+	testutil.Equals(t, "1.17", mf.GoVersion())
+
+	testutil.Ok(t, mf.SetContent([]byte("module _\n\ngo 1.18\n\nrequire my/module v1.1.0\n")))
+	expectContent(t, "module _\n\ngo 1.18\n\nrequire my/module v1.1.0\n", testFile)
+	testutil.Equals(t, "1.18", mf.GoVersion())
+}
+
+func TestFile_SequentialEdits(t *testing.T) {
+	t.Parallel()
+
+	testFile := filepath.Join(t.TempDir(), "test.mod")
+	testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.17\n\nrequire my/module v1.0.0\n"), os.ModePerm))
+
+	a, err := OpenFile(testFile)
+	testutil.Ok(t, err)
+
+	opened := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		close(opened)
+		// Blocks until a is closed.
+		b, err := OpenFile(testFile)
+		if err != nil {
+			done <- err
+			return
+		}
+		// Module file was replaced by a, b has to see the new one.
+		if _, comment := b.Module(); comment != "yolo" {
+			done <- errors.Newf("expected module comment written by the first file, got %q", comment)
+			return
+		}
+		err = b.SetGoVersion("1.18")
+		if cerr := b.Close(); err == nil {
+			err = cerr
+		}
+		done <- err
+	}()
+
+	<-opened
+	testutil.Ok(t, a.SetModule("_", "yolo"))
+	testutil.Ok(t, a.Close())
+	testutil.Ok(t, <-done)
+
+	expectContent(t, "module _ // yolo\n\ngo 1.18\n\nrequire my/module v1.0.0\n", testFile)
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	mf, err := Parse("test.mod", strings.NewReader(`module _
+
+go 1.17
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+`))
+	testutil.Ok(t, err)
+
+	testutil.Equals(t, "test.mod", mf.Filepath())
+	testutil.Equals(t, "1.17", mf.GoVersion())
+	testutil.Equals(t, []RequireDirective{{
+		Module:             module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+		ExtraSuffixComment: "cmd/prometheus",
+	}}, mf.RequireDirectives())
+
+	testutil.Ok(t, mf.SetModule("_", "yolo"))
+	testutil.Ok(t, mf.SetRequireDirectives(RequireDirective{Module: module.Version{Path: "my/module", Version: "v1.0.0"}, ExtraSuffixComment: "yolo"}))
+	testutil.Ok(t, mf.Reload())
+
+	b := &bytes.Buffer{}
+	_, err = mf.WriteTo(b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, `module _ // yolo
+
+go 1.17
+
+require my/module v1.0.0 // yolo
+`, b.String())
+	testutil.Ok(t, mf.Close())
+
+	_, err = Parse("test.mod", strings.NewReader(`require`))
+	testutil.NotOk(t, err)
+	testutil.Equals(t, "parse: test.mod:1: usage: require module/path v1.2.3", err.Error())
+}
+
+type mutexLocker struct {
+	mu     sync.Mutex
+	locked int
+}
+
+func (l *mutexLocker) Lock(*os.File) error {
+	l.mu.Lock()
+	l.locked++
+	return nil
+}
+
+func (l *mutexLocker) Unlock(*os.File) error {
+	l.mu.Unlock()
+	return nil
+}
+
+func TestFile_ConcurrentEdits(t *testing.T) {
+	t.Parallel()
+
+	for _, tcase := range []struct {
+		name   string
+		locker Locker
+	}{
+		{name: "injected locker", locker: &mutexLocker{}},
+		{name: "default locker", locker: DefaultLocker},
+	} {
+		t.Run(tcase.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "test.mod")
+			testutil.Ok(t, os.WriteFile(testFile, []byte("module _\n\ngo 1.17\n\nrequire my/module v1.0.0\n"), os.ModePerm))
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 20)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < 20; j++ {
+						mf, err := OpenFileForRead(testFile)
+						if err != nil {
+							errs <- err
+							return
+						}
+						// Readers should never see partially written file.
+						if n := len(mf.RequireDirectives()); n != 1 {
+							errs <- errors.Newf("expected one require directive, got %d", n)
+						}
+						if err := mf.Close(); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}()
+			}
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					for j := 0; j < 20; j++ {
+						mf, err := OpenFileWithLocker(testFile, tcase.locker)
+						if err != nil {
+							errs <- err
+							return
+						}
+						// Read, modify and write, so any edit made in between would be lost.
+						comment := mf.RequireDirectives()[0].ExtraSuffixComment
+						err = mf.SetRequireComment("my/module", strings.TrimSpace(comment+fmt.Sprintf(" w%d-%d", i, j)))
+						if cerr := mf.Close(); err == nil {
+							err = cerr
+						}
+						if err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				testutil.Ok(t, err)
+			}
+
+			mf, err := OpenFileForRead(testFile)
+			testutil.Ok(t, err)
+			testutil.Equals(t, 1, len(mf.RequireDirectives()))
+			got := strings.Fields(mf.RequireDirectives()[0].ExtraSuffixComment)
+			testutil.Ok(t, mf.Close())
+
+			testutil.Equals(t, 10*20, len(got))
+			seen := map[string]struct{}{}
+			for _, u := range got {
+				seen[u] = struct{}{}
+			}
+			for i := 0; i < 10; i++ {
+				for j := 0; j < 20; j++ {
+					_, ok := seen[fmt.Sprintf("w%d-%d", i, j)]
+					testutil.Assert(t, ok, "update w%d-%d lost", i, j)
+				}
+			}
+
+			if l, ok := tcase.locker.(*mutexLocker); ok {
+				// Each open for edits is locked once, reads are not locked.
+				testutil.Equals(t, 10*20, l.locked)
+			}
+		})
+	}
+}
+
+func TestParseWithDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	content := `module _
+
+go 1.17
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/efficientgo/core
+)
+
+replace github.com/miekg/dns => 
+`
+	mf, diags, err := ParseWithDiagnostics("test.mod", strings.NewReader(content))
+	testutil.NotOk(t, err)
+	testutil.Assert(t, mf == nil)
+	_, perr := Parse("test.mod", strings.NewReader(content))
+	testutil.Equals(t, perr.Error(), err.Error())
+	testutil.Equals(t, []Diagnostic{
+		{Line: 7, Col: 2, Message: "usage: require module/path v1.2.3"},
+		{Line: 10, Col: 1, Message: "usage: replace module/path [v1.2.3] => other/module v1.4\n\t or replace module/path [v1.2.3] => ../local/directory"},
+	}, diags)
+
+	mf, diags, err = ParseWithDiagnostics("test.mod", strings.NewReader("module _\n\ngo 1.17\n"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 0, len(diags))
+	testutil.Equals(t, "1.17", mf.GoVersion())
+}
+
+func TestFile_SetSortRequires(t *testing.T) {
+	t.Parallel()
+
+	mf, err := Parse("test.mod", strings.NewReader(`module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.17
+
+require github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+
+require (
+	// Fork with fixes.
+	github.com/fatih/faillint v1.5.0
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+	github.com/bwplotka/mdox v0.9.0 // CGO_ENABLED=0
+	github.com/alecthomas/kingpin v2.2.6+incompatible // indirect
+)
+`))
+	testutil.Ok(t, err)
+	mf.SetSortRequires(true)
+	testutil.Ok(t, mf.SetGoVersion("1.17"))
+
+	expected := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.17
+
+require github.com/bwplotka/mdox v0.9.0 // CGO_ENABLED=0
+
+require (
+	// Fork with fixes.
+	github.com/fatih/faillint v1.5.0
+	github.com/efficientgo/core v1.0.0-rc.0 // indirect
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/alecthomas/kingpin v2.2.6+incompatible // indirect
+)
+`
+	b := bytes.Buffer{}
+	_, err = mf.WriteTo(&b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, b.String())
+	testutil.Equals(t, "github.com/bwplotka/mdox", mf.RequireDirectives()[0].Module.Path)
+
+	// Stable.
+	testutil.Ok(t, mf.SetGoVersion("1.17"))
+	b.Reset()
+	_, err = mf.WriteTo(&b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, b.String())
+}
+
+func TestParseLenient(t *testing.T) {
+	t.Parallel()
+
+	mf, errs := ParseLenient("test.mod", strings.NewReader(`module _
+
+go 1.17
+
+require (
+	github.com/prometheus/prometheus v2.4.3+incompatible // cmd/prometheus
+	github.com/efficientgo/core
+)
+
+replace github.com/miekg/dns => 
+
+exclude github.com/miekg/dns v1.0.5
+`))
+	testutil.Equals(t, 2, len(errs))
+	testutil.Equals(t, "test.mod:7:2: usage: require module/path v1.2.3", errs[0].Error())
+	testutil.Equals(t, "test.mod:10: usage: replace module/path [v1.2.3] => other/module v1.4\n\t or replace module/path [v1.2.3] => ../local/directory", errs[1].Error())
+
+	testutil.Equals(t, []RequireDirective{{
+		Module:             module.Version{Path: "github.com/prometheus/prometheus", Version: "v2.4.3+incompatible"},
+		ExtraSuffixComment: "cmd/prometheus",
+	}}, mf.RequireDirectives())
+	testutil.Equals(t, 0, len(mf.ReplaceDirectives()))
+	testutil.Equals(t, 1, len(mf.ExcludeDirectives()))
+
+	mf, errs = ParseLenient("test.mod", strings.NewReader("module _\n\ngo 1.17\n"))
+	testutil.Equals(t, 0, len(errs))
+	testutil.Equals(t, "1.17", mf.GoVersion())
+}
+
+func TestFile_Toolchain(t *testing.T) {
+	t.Parallel()
+
+	content := `module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT
+
+go 1.21
+
+toolchain go1.21.3
+
+require github.com/fatih/faillint v1.5.0
+`
+	mf, err := Parse("test.mod", strings.NewReader(content))
+	testutil.Ok(t, err)
+	testutil.Equals(t, "go1.21.3", mf.Toolchain())
+	testutil.Equals(t, "1.21", mf.GoVersion())
+
+	// Kept on format.
+	testutil.Ok(t, mf.SetRequireDirectives(RequireDirective{Module: module.Version{Path: "github.com/fatih/faillint", Version: "v1.6.0"}}))
+	b := bytes.Buffer{}
+	_, err = mf.WriteTo(&b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, strings.Replace(content, "v1.5.0", "v1.6.0", 1), b.String())
+
+	testutil.Ok(t, mf.SetToolchain("go1.22.0"))
+	testutil.Equals(t, "go1.22.0", mf.Toolchain())
+	testutil.Ok(t, mf.SetToolchain(""))
+	b.Reset()
+	_, err = mf.WriteTo(&b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "module _ // Auto generated by https://github.com/bwplotka/bingo. DO NOT EDIT\n\ngo 1.21\n\nrequire github.com/fatih/faillint v1.6.0\n", b.String())
+
+	testutil.NotOk(t, mf.SetToolchain("1.22"))
+
+	// Added after module line if there is no go directive.
+	mf, err = Parse("test.mod", strings.NewReader("module _\n"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, "", mf.Toolchain())
+	testutil.Ok(t, mf.SetToolchain("go1.21.3"))
+	b.Reset()
+	_, err = mf.WriteTo(&b)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "module _\n\ntoolchain go1.21.3\n", b.String())
+
+	_, err = Parse("test.mod", strings.NewReader("module _\n\ntoolchain go1.21.3\ntoolchain go1.21.4\n"))
+	testutil.NotOk(t, err)
+	testutil.Equals(t, "test.mod:4: repeated toolchain statement", err.Error())
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package mod
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/efficientgo/core/errors"
+	"golang.org/x/mod/module"
+)
+
+// SumEntries parses go.sum file and returns hashes by "<module path>@<version>" key. Hashes of go.mod files only are
+// keyed by "<module path>@<version>/go.mod", as in the go.sum file itself.
+// If the file does not exist, empty entries are returned.
+func SumEntries(sumFile string) (map[string][]string, error) {
+	entries := map[string][]string{}
+
+	b, err := os.ReadFile(sumFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, errors.Wrap(err, "read")
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for i := 1; s.Scan(); i++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 3 {
+			return nil, errors.Newf("%s:%d: malformed go.sum line, expected '<module> <version> <hash>', got %q", sumFile, i, s.Text())
+		}
+		key := f[0] + "@" + f[1]
+		entries[key] = append(entries[key], f[2])
+	}
+	return entries, s.Err()
+}
+
+// PruneSum rewrites go.sum file keeping only hashes (including go.mod hashes) of the given module versions, e.g. after
+// upgrade, so hashes of the old version do not stay behind. Keep should have all modules of the module graph, as go
+// needs go.mod hashes of all of them. Empty lines are removed. File is not touched if nothing would be removed.
+func PruneSum(sumFile string, keep []module.Version) error {
+	b, err := os.ReadFile(sumFile)
+	if err != nil {
+		return errors.Wrap(err, "read")
+	}
+
+	kept := map[string]struct{}{}
+	for _, m := range keep {
+		kept[m.Path+" "+m.Version] = struct{}{}
+		kept[m.Path+" "+m.Version+"/go.mod"] = struct{}{}
+	}
+
+	var (
+		out     bytes.Buffer
+		changed bool
+	)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for i := 1; s.Scan(); i++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			changed = true
+			continue
+		}
+		if len(f) != 3 {
+			return errors.Newf("%s:%d: malformed go.sum line, expected '<module> <version> <hash>', got %q", sumFile, i, s.Text())
+		}
+		if _, ok := kept[f[0]+" "+f[1]]; !ok {
+			changed = true
+			continue
+		}
+		out.WriteString(s.Text())
+		out.WriteString("\n")
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	return writeFileAtomic(sumFile, out.Bytes())
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Bartłomiej Płotka @bwplotka
+// Licensed under the Apache License 2.0.
+
+package mod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/efficientgo/core/testutil"
+	"golang.org/x/mod/module"
+)
+
+func TestSumEntries(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+
+	t.Run("not existing file", func(t *testing.T) {
+		t.Parallel()
+
+		entries, err := SumEntries(filepath.Join(tmpDir, "not-existing.sum"))
+		testutil.Ok(t, err)
+		testutil.Equals(t, map[string][]string{}, entries)
+	})
+	t.Run("sum file", func(t *testing.T) {
+		t.Parallel()
+
+		testFile := filepath.Join(tmpDir, "test.sum")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`github.com/Masterminds/semver v1.5.0 h1:H65muMkzWKEuNDnfl9d70GUjFniHKHRKFPGuZUTGdkg=
+github.com/Masterminds/semver v1.5.0/go.mod h1:MB6lktGJrhw8PrUyiEoblNEGEQ+RzHPF078ddwwvV3Y=
+
+github.com/oklog/run v1.1.0 h1:GEenZ1cK0+q0+wsJew9qUg/DyD8k3JzYsZAi5gYi2mA=
+github.com/oklog/run v1.1.0/go.mod h1:sVPdnTZT1zYwAJeCMu2Th4T21pA3FPOQRfWjQlk7DVU=
+`), os.ModePerm))
+
+		entries, err := SumEntries(testFile)
+		testutil.Ok(t, err)
+		testutil.Equals(t, map[string][]string{
+			"github.com/Masterminds/semver@v1.5.0":        {"h1:H65muMkzWKEuNDnfl9d70GUjFniHKHRKFPGuZUTGdkg="},
+			"github.com/Masterminds/semver@v1.5.0/go.mod": {"h1:MB6lktGJrhw8PrUyiEoblNEGEQ+RzHPF078ddwwvV3Y="},
+			"github.com/oklog/run@v1.1.0":                 {"h1:GEenZ1cK0+q0+wsJew9qUg/DyD8k3JzYsZAi5gYi2mA="},
+			"github.com/oklog/run@v1.1.0/go.mod":          {"h1:sVPdnTZT1zYwAJeCMu2Th4T21pA3FPOQRfWjQlk7DVU="},
+		}, entries)
+	})
+	t.Run("malformed sum file", func(t *testing.T) {
+		t.Parallel()
+
+		testFile := filepath.Join(tmpDir, "test2.sum")
+		testutil.Ok(t, os.WriteFile(testFile, []byte(`github.com/oklog/run v1.1.0 h1:GEenZ1cK0+q0+wsJew9qUg/DyD8k3JzYsZAi5gYi2mA=
+github.com/oklog/run v1.1.0/go.mod
+`), os.ModePerm))
+
+		_, err := SumEntries(testFile)
+		testutil.NotOk(t, err)
+		testutil.Equals(t, testFile+`:2: malformed go.sum line, expected '<module> <version> <hash>', got "github.com/oklog/run v1.1.0/go.mod"`, err.Error())
+	})
+}
+
+func TestPruneSum(t *testing.T) {
+	t.Parallel()
+
+	content := `github.com/Masterminds/semver v1.4.0 h1:old=
+github.com/Masterminds/semver v1.4.0/go.mod h1:oldmod=
+github.com/Masterminds/semver v1.5.0 h1:H65muMkzWKEuNDnfl9d70GUjFniHKHRKFPGuZUTGdkg=
+github.com/Masterminds/semver v1.5.0/go.mod h1:MB6lktGJrhw8PrUyiEoblNEGEQ+RzHPF078ddwwvV3Y=
+
+github.com/oklog/run v1.1.0/go.mod h1:sVPdnTZT1zYwAJeCMu2Th4T21pA3FPOQRfWjQlk7DVU=
+`
+	testFile := filepath.Join(t.TempDir(), "test.sum")
+	testutil.Ok(t, os.WriteFile(testFile, []byte(content), os.ModePerm))
+
+	keep := []module.Version{{Path: "github.com/Masterminds/semver", Version: "v1.5.0"}, {Path: "github.com/oklog/run", Version: "v1.1.0"}}
+	testutil.Ok(t, PruneSum(testFile, keep))
+	expected := `github.com/Masterminds/semver v1.5.0 h1:H65muMkzWKEuNDnfl9d70GUjFniHKHRKFPGuZUTGdkg=
+github.com/Masterminds/semver v1.5.0/go.mod h1:MB6lktGJrhw8PrUyiEoblNEGEQ+RzHPF078ddwwvV3Y=
+github.com/oklog/run v1.1.0/go.mod h1:sVPdnTZT1zYwAJeCMu2Th4T21pA3FPOQRfWjQlk7DVU=
+`
+	b, err := os.ReadFile(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, expected, string(b))
+
+	// Already minimal file is not rewritten.
+	fi, err := os.Stat(testFile)
+	testutil.Ok(t, err)
+	testutil.Ok(t, PruneSum(testFile, keep))
+	fi2, err := os.Stat(testFile)
+	testutil.Ok(t, err)
+	testutil.Equals(t, fi.ModTime(), fi2.ModTime())
+
+	testutil.NotOk(t, PruneSum(filepath.Join(t.TempDir(), "not-existing.sum"), keep))
+}
@@ -94,19 +94,31 @@ var cmdsSupportingModFileArg = map[string]struct{}{
 }
 
 func (r *Runner) execGo(ctx context.Context, output io.Writer, e envars.EnvSlice, cd string, modFile string, args ...string) error {
-	if modFile != "" {
-		for i, arg := range args {
-			if _, ok := cmdsSupportingModFileArg[arg]; ok {
-				if i == len(args)-1 {
-					args = append(args, fmt.Sprintf("-modfile=%s", modFile))
-					break
-				}
-				args = append(args[:i+1], append([]string{fmt.Sprintf("-modfile=%s", modFile)}, args[i+1:]...)...)
-				break
-			}
+	return r.exec(ctx, output, e, cd, r.goCmd, GoArgs(modFile, args...)...)
+}
+
+// GoArgs returns arguments go command is invoked with for the given args. If modFile is not empty, -modfile flag is
+// added right after the first command that supports it.
+func GoArgs(modFile string, args ...string) []string {
+	if modFile == "" {
+		return args
+	}
+	for i, arg := range args {
+		if _, ok := cmdsSupportingModFileArg[arg]; ok {
+			ret := make([]string, 0, len(args)+1)
+			ret = append(ret, args[:i+1]...)
+			ret = append(ret, fmt.Sprintf("-modfile=%s", modFile))
+			return append(ret, args[i+1:]...)
 		}
 	}
-	return r.exec(ctx, output, e, cd, r.goCmd, args...)
+	return args
+}
+
+// BuildArgs returns arguments go command is invoked with by Build for the given module file, package, output binary
+// path and extra build flags. Package version is not part of the arguments, it's pinned in the module file.
+func BuildArgs(modFile, pkg, out string, flags ...string) []string {
+	args := append([]string{"build", "-o=" + out}, flags...)
+	return GoArgs(modFile, append(args, pkg)...)
 }
 
 func (r *Runner) exec(ctx context.Context, output io.Writer, e envars.EnvSlice, cd string, command string, args ...string) error {
@@ -212,9 +224,8 @@ func (r *runnable) GetD(packages ...string) (string, error) {
 
 // Build runs 'go build' against separate go modules file with given packages.
 func (r *runnable) Build(pkg, out string, args ...string) error {
-	args = append([]string{"build", "-o=" + out}, args...)
 	output := &bytes.Buffer{}
-	if err := r.r.execGo(r.ctx, output, r.extraEnvVars, r.dir, r.modFile, append(args, pkg)...); err != nil {
+	if err := r.r.exec(r.ctx, output, r.extraEnvVars, r.dir, r.r.goCmd, BuildArgs(r.modFile, pkg, out, args...)...); err != nil {
 		return errors.Wrap(err, output.String())
 	}
 
@@ -4,6 +4,7 @@
 package runner
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/efficientgo/core/errors"
@@ -54,3 +55,40 @@ func TestParseAndIsSupportedVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildArgs(t *testing.T) {
+	for _, tcase := range []struct {
+		modFile, pkg, out string
+		flags             []string
+		expected          []string
+	}{
+		{
+			pkg: "github.com/bwplotka/bingo", out: "/bin/bingo",
+			expected: []string{"build", "-o=/bin/bingo", "github.com/bwplotka/bingo"},
+		},
+		{
+			modFile: ".bingo/promtool.mod", pkg: "github.com/prometheus/prometheus/cmd/promtool", out: "/bin/promtool-v2.4.3",
+			expected: []string{"build", "-modfile=.bingo/promtool.mod", "-o=/bin/promtool-v2.4.3", "github.com/prometheus/prometheus/cmd/promtool"},
+		},
+		{
+			modFile: ".bingo/promtool.mod", pkg: "github.com/prometheus/prometheus/cmd/promtool", out: "/bin/promtool-v2.4.3", flags: []string{"-tags=netgo", "-ldflags=-s"},
+			expected: []string{"build", "-modfile=.bingo/promtool.mod", "-o=/bin/promtool-v2.4.3", "-tags=netgo", "-ldflags=-s", "github.com/prometheus/prometheus/cmd/promtool"},
+		},
+	} {
+		t.Run(strings.Join(tcase.expected, " "), func(t *testing.T) {
+			testutil.Equals(t, tcase.expected, BuildArgs(tcase.modFile, tcase.pkg, tcase.out, tcase.flags...))
+		})
+	}
+}
+
+func TestGoArgs(t *testing.T) {
+	args := []string{"mod", "download"}
+	testutil.Equals(t, []string{"mod", "download"}, GoArgs("a.mod", args...))
+	testutil.Equals(t, []string{"list", "-modfile=a.mod"}, GoArgs("a.mod", "list"))
+	testutil.Equals(t, []string{"env", "GOPATH"}, GoArgs("", "env", "GOPATH"))
+
+	// Given args are not modified.
+	args = []string{"get", "-d", "pkg"}
+	testutil.Equals(t, []string{"get", "-modfile=a.mod", "-d"}, GoArgs("a.mod", args[:2]...))
+	testutil.Equals(t, []string{"get", "-d", "pkg"}, args)
+}